@@ -0,0 +1,207 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	archiveVersion = 1
+
+	// scrypt parameters recommended for interactive logins
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltLen      = 16
+)
+
+const (
+	// MaxArchiveSize bounds the decompressed archive, a small crafted file must not expand without limit
+	MaxArchiveSize = 256 << 20
+	// MaxFileSize bounds a backup file, it is the base64 of the compressed archive in a small envelope
+	// so a larger file can not hold an archive within MaxArchiveSize
+	MaxFileSize = MaxArchiveSize/3*4 + 1<<20
+)
+
+// maxArchiveSize is MaxArchiveSize, the tests lower it
+var maxArchiveSize = MaxArchiveSize
+
+var (
+	ErrEmptyPassphrase = errors.New("passphrase should be filled")
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted backup")
+	ErrUnknownVersion  = errors.New("unknown backup version")
+	ErrInvalidBackup   = errors.New("invalid backup")
+)
+
+// Archive is the content of a backup, the keys of every exported session.
+type Archive struct {
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Sessions  []SessionDump `json:"sessions"`
+}
+
+// SessionDump is every stored row of a single device.
+type SessionDump struct {
+	JID    string      `json:"jid"`
+	Tables []TableDump `json:"tables"`
+}
+
+// TableDump is the rows of a single whatsmeow table that belong to the device.
+type TableDump struct {
+	Name    string    `json:"name"`
+	Columns []string  `json:"columns"`
+	Rows    [][]Value `json:"rows"`
+}
+
+// Value is a typed column value, the type is kept so binary keys survive the json encoding.
+type Value struct {
+	Type string `json:"t"`
+	Data string `json:"v,omitempty"`
+}
+
+// envelope is the encrypted file format of an archive.
+type envelope struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    string `json:"salt"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+// Encrypt compresses the archive and encrypts it with AES-256-GCM using a key derived from the passphrase with scrypt.
+func Encrypt(archive Archive, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+
+	plain, err := json.Marshal(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err = gz.Write(plain); err != nil {
+		return nil, err
+	}
+	if err = gz.Close(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{
+		Version: archiveVersion,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, compressed.Bytes(), nil)),
+	})
+}
+
+// Decrypt opens an archive created by Encrypt.
+func Decrypt(data []byte, passphrase string) (archive Archive, err error) {
+	if passphrase == "" {
+		return archive, ErrEmptyPassphrase
+	}
+
+	var env envelope
+	if err = json.Unmarshal(data, &env); err != nil {
+		return archive, fmt.Errorf("invalid backup file: %w", err)
+	}
+	if env.Version != archiveVersion || env.KDF != "scrypt" {
+		return archive, ErrUnknownVersion
+	}
+	// the kdf parameters come from the file, only the ones Encrypt uses are accepted so a crafted file
+	// can not make scrypt allocate an unbounded amount of memory
+	if env.N != scryptN || env.R != scryptR || env.P != scryptP {
+		return archive, fmt.Errorf("%w: unsupported kdf parameters n=%d r=%d p=%d", ErrInvalidBackup, env.N, env.R, env.P)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(env.Salt)
+	if err != nil {
+		return archive, fmt.Errorf("invalid backup salt: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return archive, fmt.Errorf("invalid backup nonce: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return archive, fmt.Errorf("invalid backup data: %w", err)
+	}
+
+	aead, err := newAEAD(passphrase, salt, env.N, env.R, env.P)
+	if err != nil {
+		return archive, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return archive, ErrWrongPassphrase
+	}
+	compressed, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return archive, ErrWrongPassphrase
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return archive, err
+	}
+	defer gz.Close()
+	plain, err := io.ReadAll(io.LimitReader(gz, int64(maxArchiveSize)+1))
+	if err != nil {
+		return archive, err
+	}
+	if len(plain) > maxArchiveSize {
+		return archive, fmt.Errorf("%w: the archive is larger than %d MB", ErrInvalidBackup, maxArchiveSize>>20)
+	}
+
+	if err = json.Unmarshal(plain, &archive); err != nil {
+		return archive, fmt.Errorf("invalid backup content: %w", err)
+	}
+	if archive.Version != archiveVersion {
+		return archive, ErrUnknownVersion
+	}
+	return archive, nil
+}
+
+func newAEAD(passphrase string, salt []byte, n, r, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, n, r, p, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	archive := Archive{
+		Version:   archiveVersion,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Sessions: []SessionDump{{
+			JID: "6281@s.whatsapp.net",
+			Tables: []TableDump{{
+				Name:    "whatsmeow_device",
+				Columns: []string{"jid", "noise_key"},
+				Rows:    [][]Value{{{Type: "string", Data: "6281@s.whatsapp.net"}, {Type: "bytes", Data: "AAEC"}}},
+			}},
+		}},
+	}
+
+	data, err := Encrypt(archive, "secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	got, err := Decrypt(data, "secret")
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	want, _ := json.Marshal(archive)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(gotJSON, want) {
+		t.Fatalf("got %s, want %s", gotJSON, want)
+	}
+
+	if _, err = Decrypt(data, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Decrypt with a wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err = Encrypt(archive, ""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Fatalf("Encrypt without passphrase: err = %v, want ErrEmptyPassphrase", err)
+	}
+}
+
+func TestDecryptRejectsKDFParameters(t *testing.T) {
+	data, err := Encrypt(Archive{Version: archiveVersion}, "secret")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		edit func(env *envelope)
+	}{
+		{name: "huge n", edit: func(env *envelope) { env.N = 1 << 30 }},
+		{name: "huge r", edit: func(env *envelope) { env.R = 1 << 20 }},
+		{name: "huge p", edit: func(env *envelope) { env.P = 1 << 20 }},
+		{name: "weak n", edit: func(env *envelope) { env.N = 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var env envelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(&env)
+			crafted, _ := json.Marshal(env)
+			if _, err := Decrypt(crafted, "secret"); !errors.Is(err, ErrInvalidBackup) {
+				t.Fatalf("err = %v, want ErrInvalidBackup", err)
+			}
+		})
+	}
+}
+
+func TestDecryptBoundsTheArchiveSize(t *testing.T) {
+	defer func(size int) { maxArchiveSize = size }(maxArchiveSize)
+	maxArchiveSize = 1 << 20
+
+	// zeros compress to a small file that expands past the limit
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := io.CopyN(gz, zeroReader{}, int64(maxArchiveSize)*4); err != nil {
+		t.Fatal(err)
+	}
+	_ = gz.Close()
+
+	salt := make([]byte, saltLen)
+	_, _ = rand.Read(salt)
+	aead, err := newAEAD("secret", salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	crafted, _ := json.Marshal(envelope{
+		Version: archiveVersion,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, compressed.Bytes(), nil)),
+	})
+
+	if _, err = Decrypt(crafted, "secret"); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("err = %v, want ErrInvalidBackup", err)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
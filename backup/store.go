@@ -0,0 +1,233 @@
+package backup
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// table is a whatsmeow table with the column that holds the device jid,
+// the order is the insert order so the foreign keys are satisfied on restore.
+type table struct {
+	name      string
+	jidColumn string
+}
+
+var (
+	tables = []table{
+		{"whatsmeow_device", "jid"},
+		{"whatsmeow_identity_keys", "our_jid"},
+		{"whatsmeow_pre_keys", "jid"},
+		{"whatsmeow_sessions", "our_jid"},
+		{"whatsmeow_sender_keys", "our_jid"},
+		{"whatsmeow_app_state_sync_keys", "jid"},
+		{"whatsmeow_app_state_version", "jid"},
+		{"whatsmeow_app_state_mutation_macs", "jid"},
+		{"whatsmeow_contacts", "our_jid"},
+		{"whatsmeow_chat_settings", "our_jid"},
+		{"whatsmeow_message_secrets", "our_jid"},
+		{"whatsmeow_privacy_tokens", "our_jid"},
+	}
+
+	matchColumn = regexp.MustCompile(`^[a-z_]+$`)
+)
+
+const (
+	valueNull   = "null"
+	valueBytes  = "bytes"
+	valueString = "string"
+	valueInt    = "int"
+	valueFloat  = "float"
+	valueBool   = "bool"
+	valueTime   = "time"
+)
+
+// Export reads every row of the given device jids (the full AD jid as stored on whatsmeow_device).
+func Export(db *sql.DB, jids []string) (archive Archive, err error) {
+	archive = Archive{
+		Version:   archiveVersion,
+		CreatedAt: time.Now(),
+		Sessions:  make([]SessionDump, 0, len(jids)),
+	}
+
+	for _, jid := range jids {
+		dump := SessionDump{JID: jid}
+		for _, t := range tables {
+			tableDump, errDump := exportTable(db, t, jid)
+			if errDump != nil {
+				return archive, fmt.Errorf("export %s of %s: %w", t.name, jid, errDump)
+			}
+			dump.Tables = append(dump.Tables, tableDump)
+		}
+		archive.Sessions = append(archive.Sessions, dump)
+	}
+	return archive, nil
+}
+
+func exportTable(db *sql.DB, t table, jid string) (dump TableDump, err error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s WHERE %s=$1", t.name, t.jidColumn), jid)
+	if err != nil {
+		return dump, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return dump, err
+	}
+	dump = TableDump{Name: t.name, Columns: columns, Rows: [][]Value{}}
+
+	for rows.Next() {
+		raw := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range raw {
+			pointers[i] = &raw[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return dump, err
+		}
+
+		row := make([]Value, len(columns))
+		for i, value := range raw {
+			row[i] = encodeValue(value)
+		}
+		dump.Rows = append(dump.Rows, row)
+	}
+	return dump, rows.Err()
+}
+
+// Import writes the sessions of the archive, the existing rows of the same devices are replaced.
+// every session is written in its own transaction.
+func Import(db *sql.DB, archive Archive) (imported []string, err error) {
+	for _, dump := range archive.Sessions {
+		if err = importSession(db, dump); err != nil {
+			return imported, fmt.Errorf("import %s: %w", dump.JID, err)
+		}
+		imported = append(imported, dump.JID)
+	}
+	return imported, nil
+}
+
+func importSession(db *sql.DB, dump SessionDump) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// delete in reverse order, the children first
+	for i := len(tables) - 1; i >= 0; i-- {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s=$1", tables[i].name, tables[i].jidColumn), dump.JID)
+		if err != nil {
+			return err
+		}
+	}
+
+	byName := make(map[string]TableDump, len(dump.Tables))
+	for _, tableDump := range dump.Tables {
+		byName[tableDump.Name] = tableDump
+	}
+
+	for _, t := range tables {
+		tableDump, ok := byName[t.name]
+		if !ok || len(tableDump.Rows) == 0 {
+			continue
+		}
+		if err = insertRows(tx, t, dump.JID, tableDump); err != nil {
+			return fmt.Errorf("%s: %w", t.name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertRows(tx *sql.Tx, t table, jid string, dump TableDump) error {
+	jidIndex := -1
+	placeholders := make([]string, len(dump.Columns))
+	for i, column := range dump.Columns {
+		// the column names come from the archive, only plain identifiers are allowed
+		if !matchColumn.MatchString(column) {
+			return fmt.Errorf("invalid column %q", column)
+		}
+		if column == t.jidColumn {
+			jidIndex = i
+		}
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+	}
+	if jidIndex < 0 {
+		return fmt.Errorf("column %s is missing", t.jidColumn)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.name, strings.Join(dump.Columns, ", "), strings.Join(placeholders, ", "))
+	for _, row := range dump.Rows {
+		if len(row) != len(dump.Columns) {
+			return fmt.Errorf("row has %d values for %d columns", len(row), len(dump.Columns))
+		}
+		args := make([]interface{}, len(row))
+		for i, value := range row {
+			decoded, err := decodeValue(value)
+			if err != nil {
+				return err
+			}
+			args[i] = decoded
+		}
+		// a row of another device can not be smuggled in the session
+		if fmt.Sprint(args[jidIndex]) != jid {
+			return fmt.Errorf("row belongs to %v instead of %s", args[jidIndex], jid)
+		}
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeValue(value interface{}) Value {
+	switch v := value.(type) {
+	case nil:
+		return Value{Type: valueNull}
+	case []byte:
+		return Value{Type: valueBytes, Data: base64.StdEncoding.EncodeToString(v)}
+	case string:
+		return Value{Type: valueString, Data: v}
+	case int64:
+		return Value{Type: valueInt, Data: strconv.FormatInt(v, 10)}
+	case float64:
+		return Value{Type: valueFloat, Data: strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return Value{Type: valueBool, Data: strconv.FormatBool(v)}
+	case time.Time:
+		return Value{Type: valueTime, Data: v.Format(time.RFC3339Nano)}
+	default:
+		return Value{Type: valueString, Data: fmt.Sprint(v)}
+	}
+}
+
+func decodeValue(value Value) (interface{}, error) {
+	switch value.Type {
+	case valueNull:
+		return nil, nil
+	case valueBytes:
+		return base64.StdEncoding.DecodeString(value.Data)
+	case valueString:
+		return value.Data, nil
+	case valueInt:
+		return strconv.ParseInt(value.Data, 10, 64)
+	case valueFloat:
+		return strconv.ParseFloat(value.Data, 64)
+	case valueBool:
+		return strconv.ParseBool(value.Data)
+	case valueTime:
+		return time.Parse(time.RFC3339Nano, value.Data)
+	default:
+		return nil, fmt.Errorf("unknown value type %q", value.Type)
+	}
+}
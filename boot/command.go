@@ -0,0 +1,96 @@
+package boot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/database"
+	"whatsapp_multi_session_general/session"
+)
+
+const (
+	backupPassphraseEnv = "WA_MULTI_SESSION_BACKUP_PASSPHRASE"
+)
+
+// RunCommand runs a cli subcommand instead of the server, e.g.
+//
+//	whatsapp_multi_session_general -env local backup -out sessions.wabackup -senders 62811,62812
+//	whatsapp_multi_session_general -env local restore -in sessions.wabackup
+//
+// the passphrase is read from -passphrase or the WA_MULTI_SESSION_BACKUP_PASSPHRASE environment variable.
+func RunCommand(args []string) error {
+	//initialize config
+	config.Initialize()
+
+	storeConn, sqlDB, err := database.NewDatabase()
+	if err != nil {
+		return err
+	}
+	cmdHandler := commandhandler.NewCommandHandler(storeConn, sqlDB, session.NewRegistry())
+
+	switch args[0] {
+	case "backup":
+		return runBackup(cmdHandler, args[1:])
+	case "restore":
+		return runRestore(cmdHandler, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, available commands: backup, restore", args[0])
+	}
+}
+
+func runBackup(cmdHandler commandhandler.CommandHandler, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := flags.String("out", "sessions.wabackup", "the file the encrypted backup is written to")
+	senders := flags.String("senders", "", "comma separated senders to export, every session when empty")
+	passphrase := flags.String("passphrase", os.Getenv(backupPassphraseEnv), "the passphrase the backup is encrypted with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var users []string
+	if strings.TrimSpace(*senders) != "" {
+		users, _ = commandhandler.ValidateStringArrayAsStringArray(*senders)
+	}
+
+	data, exported, err := cmdHandler.BackupSessions(users, *passphrase)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(*out, data, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("backup of %d sessions %v is written to %s \n", len(exported), exported, *out)
+	return nil
+}
+
+func runRestore(cmdHandler commandhandler.CommandHandler, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	in := flags.String("in", "", "the encrypted backup file to restore")
+	passphrase := flags.String("passphrase", os.Getenv(backupPassphraseEnv), "the passphrase the backup is encrypted with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return errors.New("-in should be filled")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+
+	// the sessions are connected by the server on the next start up
+	restored, err := cmdHandler.RestoreSessions(data, *passphrase, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d sessions %v are restored from %s \n", len(restored), restored, *in)
+	return nil
+}
@@ -9,27 +9,52 @@ import (
 	"whatsapp_multi_session_general/database"
 	"whatsapp_multi_session_general/handler"
 	"whatsapp_multi_session_general/listener"
+	"whatsapp_multi_session_general/repository"
 	"whatsapp_multi_session_general/routers"
+	"whatsapp_multi_session_general/session"
+	"whatsapp_multi_session_general/stream"
+	"whatsapp_multi_session_general/webhook"
 
 	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow"
 )
 
 func Setup(r *gin.Engine) *gin.Engine {
 	//initialize config
 	config.Initialize()
 
-	//initiate database sqlite
-	sqliteConn, err := database.NewSqlite()
+	//initiate database, sqlite or postgres based on the database config
+	storeConn, sqlDB, err := database.NewDatabase()
 	if err != nil {
-		fmt.Errorf("error from initiate sqlite : %v ", err)
+		fmt.Errorf("error from initiate database : %v ", err)
 		panic(err)
 	}
 
+	//initiate session registry, shared by every component that needs the whatsmeow clients
+	sessions := session.NewRegistry()
+	sessions.OnPut(func(user string, client *whatsmeow.Client) {
+		fmt.Printf("session %s is registered \n", user)
+	})
+	sessions.OnDelete(func(user string, client *whatsmeow.Client) {
+		fmt.Printf("session %s is removed \n", user)
+	})
+
 	//initiate command handler here
-	cmdHandler := commandhandler.NewCommandHandler(sqliteConn)
+	cmdHandler := commandhandler.NewCommandHandler(storeConn, sqlDB, sessions)
 
 	listen := listener.NewListener(cmdHandler)
 
+	//initiate webhook dispatcher, every published whatsapp event is sent to the webhook of its session
+	dispatcher := webhook.NewDispatcher(repository.NewWebhookRepository(sqlDB))
+	dispatcher.Start()
+	listen.ListenForWhatsappEvent(dispatcher.Handle)
+	listen.ListenForShutdown(dispatcher.Stop)
+
+	//initiate websocket event stream, it receives the same events as the webhooks
+	hub := stream.NewHub()
+	listen.ListenForWhatsappEvent(hub.Publish)
+	listen.ListenForShutdown(hub.Close)
+
 	go func() {
 		// listener on trigger start up
 		listen.TriggerStartUp()
@@ -37,13 +62,13 @@ func Setup(r *gin.Engine) *gin.Engine {
 	//listener on trigger shutdown
 	listen.ListenForShutdownEvent()
 
-	newHandler := handler.NewHandler(cmdHandler)
+	newHandler := handler.NewHandler(cmdHandler, sessions, dispatcher, hub)
 
 	router := routers.NewRoutes(newHandler)
 	appRoutes := router.V1(r)
 
 	//initiate cronjob
-	cronJobs := cronjob.NewCronJobs(cmdHandler)
+	cronJobs := cronjob.NewCronJobs(cmdHandler, sessions)
 	go func() {
 		// listener on trigger start up
 		cronJobs.Run()
@@ -0,0 +1,77 @@
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whatsapp_multi_session_general/webhook"
+)
+
+const (
+	defaultCallbackTimeout = 10 * time.Second
+	maxCallbackResponse    = 64 << 10
+
+	userAgent = "whatsapp_multi_session_general-chatbot"
+)
+
+// callbackResponse is the body the callback responds with, an empty reply sends nothing back.
+type callbackResponse struct {
+	Reply string `json:"reply"`
+}
+
+// NewCallback returns a handler that posts the command to the url, signed like the webhooks with the secret.
+// the callback answers with {"reply": "..."}, a 404 is an unknown command and any other status than 2xx is an error.
+func NewCallback(url, secret string, timeout time.Duration) Handler {
+	if timeout <= 0 {
+		timeout = defaultCallbackTimeout
+	}
+	client := &http.Client{Timeout: timeout}
+
+	return func(ctx context.Context, cmd Command) (string, error) {
+		payload, err := json.Marshal(cmd)
+		if err != nil {
+			return "", err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return "", err
+		}
+
+		timestamp := time.Now().Unix()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(webhook.HeaderSignature, webhook.Sign(secret, timestamp, payload))
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxCallbackResponse))
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return "", ErrUnknownCommand
+		case resp.StatusCode < 200 || resp.StatusCode > 299:
+			return "", fmt.Errorf("callback responded with status %d", resp.StatusCode)
+		case resp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(body)) == 0:
+			return "", nil
+		}
+
+		var response callbackResponse
+		if err = json.Unmarshal(body, &response); err != nil {
+			return "", fmt.Errorf("invalid callback response: %w", err)
+		}
+		return response.Reply, nil
+	}
+}
@@ -0,0 +1,124 @@
+package chatbot
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Command is an inbound text that starts with the prefix of the router, e.g. "/order 2 nasi goreng".
+type Command struct {
+	Session   string   `json:"session"`
+	Chat      string   `json:"chat"`
+	Sender    string   `json:"sender"`
+	PushName  string   `json:"pushName,omitempty"`
+	MessageID string   `json:"messageId"`
+	IsGroup   bool     `json:"isGroup"`
+	Name      string   `json:"name"`
+	Args      []string `json:"args"`
+	// Text is the message without the prefix and the command name
+	Text string `json:"text"`
+}
+
+// Handler answers a command, the reply is sent back to the chat of the command unless it is empty.
+type Handler func(ctx context.Context, cmd Command) (reply string, err error)
+
+// Info describes a registered command, it is listed by the help command.
+type Info struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type registered struct {
+	info    Info
+	handler Handler
+}
+
+// Router dispatches the commands to the registered handlers, a command without a handler
+// goes to the fallback handler when there is one.
+type Router struct {
+	prefix string
+
+	mu       sync.RWMutex
+	commands map[string]registered
+	fallback Handler
+}
+
+func NewRouter(prefix string) *Router {
+	return &Router{
+		prefix:   prefix,
+		commands: make(map[string]registered),
+	}
+}
+
+// Prefix returns the prefix of the commands, the router is disabled when it is empty.
+func (r *Router) Prefix() string {
+	return r.prefix
+}
+
+// Register adds the handler of a command, a command that is already registered is replaced.
+// the name is case-insensitive.
+func (r *Router) Register(name, description string, handler Handler) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[name] = registered{info: Info{Name: name, Description: description}, handler: handler}
+}
+
+// SetFallback sets the handler of the commands that are not registered.
+func (r *Router) SetFallback(handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = handler
+}
+
+// Commands returns the registered commands ordered by name.
+func (r *Router) Commands() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]Info, 0, len(r.commands))
+	for _, command := range r.commands {
+		list = append(list, command.info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Parse splits a text into the command name and its args, it reports false when the text is not a command.
+func (r *Router) Parse(text string) (cmd Command, ok bool) {
+	text = strings.TrimSpace(text)
+	if r.prefix == "" || !strings.HasPrefix(text, r.prefix) {
+		return cmd, false
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(text, r.prefix))
+	if len(fields) == 0 {
+		return cmd, false
+	}
+	cmd.Name = strings.ToLower(fields[0])
+	cmd.Args = fields[1:]
+	cmd.Text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(text, r.prefix)), fields[0]))
+	return cmd, true
+}
+
+// Dispatch runs the handler of the command, ErrUnknownCommand is returned when there is no handler for it.
+func (r *Router) Dispatch(ctx context.Context, cmd Command) (string, error) {
+	r.mu.RLock()
+	command, ok := r.commands[cmd.Name]
+	fallback := r.fallback
+	r.mu.RUnlock()
+
+	switch {
+	case ok:
+		return command.handler(ctx, cmd)
+	case fallback != nil:
+		return fallback(ctx, cmd)
+	default:
+		return "", ErrUnknownCommand
+	}
+}
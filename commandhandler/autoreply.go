@@ -0,0 +1,387 @@
+package commandhandler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	timeOfDayLayout = "15:04"
+	// maxAutoReplyMedia is the size limit of a reply media when the media max size is not configured
+	maxAutoReplyMedia = 16 << 20
+	// maxReplyMediaRedirects bounds the redirects of the reply media url
+	maxReplyMediaRedirects = 5
+)
+
+var (
+	ErrInvalidAutoReply = errors.New("invalid auto reply")
+	errInternalAddress  = errors.New("the reply media should be on a public address")
+
+	// sharedAddressSpace is the carrier grade nat range, it is not routed on the internet like the private ranges
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+	// replyMediaTransport only connects to public addresses, the address is checked on every connection
+	// after the host is resolved so a rule can not reach the internal network, not even with a redirect
+	replyMediaTransport = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip, err := netip.ParseAddr(host); err != nil || !isPublicAddress(ip) {
+					return fmt.Errorf("%w, %s is not", errInternalAddress, host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	// autoReplyPatterns caches the compiled regex of the rules by their id, an entry is replaced when the pattern
+	// of the rule changes and removed with the rule
+	autoReplyPatterns sync.Map
+)
+
+// ValidateAutoReply checks the rule before it is stored, the error wraps ErrInvalidAutoReply.
+func ValidateAutoReply(rule repository.AutoReply) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidAutoReply, fmt.Sprintf(format, args...))
+	}
+
+	switch rule.MatchType {
+	case repository.MatchExact, repository.MatchContains:
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return invalid("pattern is empty")
+		}
+	case repository.MatchRegex:
+		if _, err := regexp.Compile(autoReplyExpr(rule)); err != nil {
+			return invalid("pattern: %v", err)
+		}
+	default:
+		return invalid("matchType should be %s, %s or %s", repository.MatchExact, repository.MatchContains, repository.MatchRegex)
+	}
+
+	if strings.TrimSpace(rule.ReplyText) == "" && rule.ReplyMediaURL == "" {
+		return invalid("replyText or replyMediaUrl should be filled")
+	}
+	if rule.ReplyMediaURL != "" {
+		parsed, err := url.Parse(rule.ReplyMediaURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return invalid("replyMediaUrl should be an http or https url")
+		}
+		ip, err := netip.ParseAddr(parsed.Hostname())
+		if strings.EqualFold(parsed.Hostname(), "localhost") || (err == nil && !isPublicAddress(ip)) {
+			return invalid("replyMediaUrl should be on a public address")
+		}
+	}
+
+	if (rule.StartTime == "") != (rule.EndTime == "") {
+		return invalid("startTime and endTime should be filled together")
+	}
+	for _, value := range []string{rule.StartTime, rule.EndTime} {
+		if _, err := time.Parse(timeOfDayLayout, value); value != "" && err != nil {
+			return invalid("time %q should be HH:MM", value)
+		}
+	}
+	if _, err := time.LoadLocation(rule.Timezone); err != nil {
+		return invalid("timezone: %v", err)
+	}
+	if rule.Cooldown < 0 {
+		return invalid("cooldown should not be negative")
+	}
+	return nil
+}
+
+// ListAutoReplies returns the auto-reply rules of the sender in the order they are evaluated.
+func (ch CommandHandler) ListAutoReplies(sender types.JID) ([]repository.AutoReply, error) {
+	return ch.AutoReplies.List(sender.User)
+}
+
+// GetAutoReply returns an auto-reply rule of the sender.
+func (ch CommandHandler) GetAutoReply(sender types.JID, id string) (repository.AutoReply, error) {
+	return ch.AutoReplies.Get(sender.User, id)
+}
+
+// CreateAutoReply validates and stores a new auto-reply rule of the sender.
+func (ch CommandHandler) CreateAutoReply(sender types.JID, rule repository.AutoReply) (repository.AutoReply, error) {
+	if err := ValidateAutoReply(rule); err != nil {
+		return rule, err
+	}
+
+	rule.ID = newAutoReplyID()
+	rule.Session = sender.User
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+	return rule, ch.AutoReplies.Create(rule)
+}
+
+// UpdateAutoReply validates and replaces an auto-reply rule of the sender, the cooldowns of the rule are kept.
+func (ch CommandHandler) UpdateAutoReply(sender types.JID, id string, rule repository.AutoReply) (repository.AutoReply, error) {
+	if err := ValidateAutoReply(rule); err != nil {
+		return rule, err
+	}
+
+	current, err := ch.AutoReplies.Get(sender.User, id)
+	if err != nil {
+		return rule, err
+	}
+	rule.ID = current.ID
+	rule.Session = current.Session
+	rule.CreatedAt = current.CreatedAt
+	rule.UpdatedAt = time.Now()
+
+	found, err := ch.AutoReplies.Update(rule)
+	if err == nil && !found {
+		err = repository.ErrNotFound
+	}
+	autoReplyPatterns.Delete(id)
+	return rule, err
+}
+
+// DeleteAutoReply removes an auto-reply rule of the sender.
+func (ch CommandHandler) DeleteAutoReply(sender types.JID, id string) error {
+	found, err := ch.AutoReplies.Delete(sender.User, id)
+	if err == nil && !found {
+		err = repository.ErrNotFound
+	}
+	if found {
+		autoReplyPatterns.Delete(id)
+	}
+	return err
+}
+
+// autoReply answers an incoming text with the first rule of the session that matches it,
+// a rule that matches within its cooldown stops the evaluation so a lower rule does not answer instead.
+func (ch CommandHandler) autoReply(user string, evt *events.Message) {
+	if !config.Conf.AutoReply.Enable || ch.AutoReplies == nil || !isAnswerable(evt) {
+		return
+	}
+
+	content := parseMessageContent(evt.Message)
+	text := strings.TrimSpace(content.Text)
+	if content.Type != MessageTypeText || text == "" {
+		return
+	}
+
+	rules, err := ch.AutoReplies.List(user)
+	if err != nil {
+		fmt.Printf("err AutoReplies.List %s : %v \n", user, err)
+		return
+	}
+	if len(rules) == 0 || ch.optedOut(user, evt.Info.Sender) {
+		return
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		if !rule.Enabled || (evt.Info.IsGroup && !rule.Groups) || !inTimeWindow(rule, now) || !matchAutoReply(rule, text) {
+			continue
+		}
+
+		contact := evt.Info.Sender.ToNonAD().String()
+		claimed, err := ch.AutoReplies.ClaimReply(rule.ID, contact, now, time.Duration(rule.Cooldown)*time.Second)
+		if err != nil {
+			fmt.Printf("err AutoReplies.ClaimReply %s : %v \n", rule.ID, err)
+			return
+		}
+		if claimed {
+			ch.sendAutoReply(user, evt.Info.Chat.ToNonAD().String(), rule)
+		}
+		return
+	}
+}
+
+// sendAutoReply sends the reply of the rule to the chat through the same path as the send endpoints.
+func (ch CommandHandler) sendAutoReply(user, chat string, rule repository.AutoReply) {
+	sender := types.NewJID(user, types.DefaultUserServer)
+
+	if rule.ReplyMediaURL == "" {
+		if _, err := ch.HandleSendNewTextMessage(sender, rule.ReplyText, chat); err != nil {
+			fmt.Printf("err auto reply %s : %v \n", rule.ID, err)
+		}
+		return
+	}
+
+	data, err := fetchReplyMedia(rule.ReplyMediaURL)
+	if err != nil {
+		fmt.Printf("err auto reply media %s : %v \n", rule.ID, err)
+		return
+	}
+
+	recipients := []string{chat}
+	mimeType := http.DetectContentType(data)
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		_, err = ch.NewHandleSendImage(sender, recipients, data, rule.ReplyText)
+	case strings.HasPrefix(mimeType, "video/"):
+		_, err = ch.NewHandleSendVideo(sender, recipients, data, rule.ReplyText)
+	case strings.HasPrefix(mimeType, "audio/"):
+		_, err = ch.NewHandleSendAudio(sender, recipients, data)
+	default:
+		fileName := "document"
+		if parsed, parseErr := url.Parse(rule.ReplyMediaURL); parseErr == nil {
+			if name := path.Base(parsed.Path); name != "/" && name != "." {
+				fileName = name
+			}
+		}
+		_, err = ch.NewHandleSendDocument(sender, recipients, fileName, data, rule.ReplyText)
+	}
+	if err != nil {
+		fmt.Printf("err auto reply %s : %v \n", rule.ID, err)
+	}
+}
+
+// matchAutoReply reports whether the incoming text matches the pattern of the rule.
+func matchAutoReply(rule repository.AutoReply, text string) bool {
+	switch rule.MatchType {
+	case repository.MatchExact:
+		if rule.CaseSensitive {
+			return text == strings.TrimSpace(rule.Pattern)
+		}
+		return strings.EqualFold(text, strings.TrimSpace(rule.Pattern))
+	case repository.MatchContains:
+		if rule.CaseSensitive {
+			return strings.Contains(text, rule.Pattern)
+		}
+		return strings.Contains(strings.ToLower(text), strings.ToLower(rule.Pattern))
+	case repository.MatchRegex:
+		pattern, err := autoReplyPattern(rule)
+		return err == nil && pattern.MatchString(text)
+	}
+	return false
+}
+
+// cachedPattern is the compiled regex of a rule and the expression it is compiled from.
+type cachedPattern struct {
+	expr    string
+	pattern *regexp.Regexp
+}
+
+// autoReplyPattern returns the compiled regex of the rule, it is compiled again when the pattern of the rule changed.
+func autoReplyPattern(rule repository.AutoReply) (*regexp.Regexp, error) {
+	expr := autoReplyExpr(rule)
+	if cached, ok := autoReplyPatterns.Load(rule.ID); ok && cached.(cachedPattern).expr == expr {
+		return cached.(cachedPattern).pattern, nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	autoReplyPatterns.Store(rule.ID, cachedPattern{expr: expr, pattern: pattern})
+	return pattern, nil
+}
+
+func autoReplyExpr(rule repository.AutoReply) string {
+	if rule.CaseSensitive {
+		return rule.Pattern
+	}
+	return "(?i)" + rule.Pattern
+}
+
+// inTimeWindow reports whether now is within the time of the day of the rule,
+// a rule without a window is always active and a window that ends before it starts crosses midnight.
+func inTimeWindow(rule repository.AutoReply, now time.Time) bool {
+	if rule.StartTime == "" || rule.EndTime == "" {
+		return true
+	}
+	start, errStart := time.Parse(timeOfDayLayout, rule.StartTime)
+	end, errEnd := time.Parse(timeOfDayLayout, rule.EndTime)
+	location, errLocation := time.LoadLocation(rule.Timezone)
+	if errStart != nil || errEnd != nil || errLocation != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+	switch {
+	case startMinute == endMinute:
+		return true
+	case startMinute < endMinute:
+		return minute >= startMinute && minute < endMinute
+	default:
+		return minute >= startMinute || minute < endMinute
+	}
+}
+
+// fetchReplyMedia downloads the media of a reply, it is limited to the media max size.
+func fetchReplyMedia(mediaURL string) ([]byte, error) {
+	timeout := config.Conf.Media.Timeout
+	if timeout <= 0 {
+		timeout = defaultMediaTimeout
+	}
+	maxSize := config.Conf.Media.MaxSize
+	if maxSize <= 0 {
+		maxSize = maxAutoReplyMedia
+	}
+
+	client := http.Client{
+		Transport: replyMediaTransport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxReplyMediaRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxReplyMediaRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to a %s url", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	resp, err := client.Get(mediaURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("media is larger than %d bytes", maxSize)
+	}
+	return data, nil
+}
+
+// isPublicAddress reports whether the address is routed on the internet, the loopback, private, link-local
+// and shared addresses of the internal network are not.
+func isPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+func newAutoReplyID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
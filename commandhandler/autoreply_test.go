@@ -0,0 +1,190 @@
+package commandhandler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestMatchAutoReply(t *testing.T) {
+	tests := []struct {
+		name string
+		rule repository.AutoReply
+		text string
+		want bool
+	}{
+		{name: "exact", rule: repository.AutoReply{MatchType: repository.MatchExact, Pattern: "harga"}, text: "harga", want: true},
+		{name: "exact ignores case", rule: repository.AutoReply{MatchType: repository.MatchExact, Pattern: " Harga "}, text: "HARGA", want: true},
+		{name: "exact case sensitive", rule: repository.AutoReply{MatchType: repository.MatchExact, Pattern: "Harga", CaseSensitive: true}, text: "harga", want: false},
+		{name: "exact is the whole text", rule: repository.AutoReply{MatchType: repository.MatchExact, Pattern: "harga"}, text: "harga berapa", want: false},
+		{name: "contains", rule: repository.AutoReply{MatchType: repository.MatchContains, Pattern: "ongkir"}, text: "Berapa ONGKIR ke Bandung?", want: true},
+		{name: "contains case sensitive", rule: repository.AutoReply{MatchType: repository.MatchContains, Pattern: "ongkir", CaseSensitive: true}, text: "Berapa ONGKIR?", want: false},
+		{name: "contains no match", rule: repository.AutoReply{MatchType: repository.MatchContains, Pattern: "ongkir"}, text: "halo", want: false},
+		{name: "regex", rule: repository.AutoReply{MatchType: repository.MatchRegex, Pattern: `^order\s+#\d+$`}, text: "ORDER #123", want: true},
+		{name: "regex case sensitive", rule: repository.AutoReply{MatchType: repository.MatchRegex, Pattern: `^order #\d+$`, CaseSensitive: true}, text: "ORDER #123", want: false},
+		{name: "invalid regex", rule: repository.AutoReply{MatchType: repository.MatchRegex, Pattern: `(`}, text: "(", want: false},
+		{name: "unknown match type", rule: repository.AutoReply{MatchType: "prefix", Pattern: "ha"}, text: "halo", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchAutoReply(tt.rule, tt.text); got != tt.want {
+				t.Fatalf("matchAutoReply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInTimeWindow(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 4, hour, minute, 0, 0, jakarta)
+	}
+	window := func(start, end string) repository.AutoReply {
+		return repository.AutoReply{StartTime: start, EndTime: end, Timezone: "Asia/Jakarta"}
+	}
+
+	tests := []struct {
+		name string
+		rule repository.AutoReply
+		now  time.Time
+		want bool
+	}{
+		{name: "no window", rule: repository.AutoReply{}, now: at(3, 0), want: true},
+		{name: "inside", rule: window("08:00", "17:00"), now: at(12, 0), want: true},
+		{name: "at the start", rule: window("08:00", "17:00"), now: at(8, 0), want: true},
+		{name: "at the end", rule: window("08:00", "17:00"), now: at(17, 0), want: false},
+		{name: "before", rule: window("08:00", "17:00"), now: at(7, 59), want: false},
+		{name: "across midnight late", rule: window("22:00", "06:00"), now: at(23, 30), want: true},
+		{name: "across midnight early", rule: window("22:00", "06:00"), now: at(5, 59), want: true},
+		{name: "across midnight outside", rule: window("22:00", "06:00"), now: at(12, 0), want: false},
+		{name: "whole day", rule: window("09:00", "09:00"), now: at(3, 0), want: true},
+		{name: "timezone of the rule", rule: window("08:00", "17:00"), now: time.Date(2024, 3, 4, 2, 0, 0, 0, time.UTC), want: true},
+		{name: "invalid time", rule: window("8am", "17:00"), now: at(12, 0), want: false},
+		{name: "invalid timezone", rule: repository.AutoReply{StartTime: "08:00", EndTime: "17:00", Timezone: "Mars/Base"}, now: at(12, 0), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inTimeWindow(tt.rule, tt.now); got != tt.want {
+				t.Fatalf("inTimeWindow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAutoReply(t *testing.T) {
+	valid := repository.AutoReply{MatchType: repository.MatchContains, Pattern: "harga", ReplyText: "cek katalog"}
+	with := func(change func(rule *repository.AutoReply)) repository.AutoReply {
+		rule := valid
+		change(&rule)
+		return rule
+	}
+
+	tests := []struct {
+		name    string
+		rule    repository.AutoReply
+		wantErr bool
+	}{
+		{name: "valid", rule: valid},
+		{name: "media reply", rule: with(func(r *repository.AutoReply) { r.ReplyText, r.ReplyMediaURL = "", "https://example.com/a.jpg" })},
+		{name: "window", rule: with(func(r *repository.AutoReply) { r.StartTime, r.EndTime, r.Timezone = "22:00", "06:00", "Asia/Jakarta" })},
+		{name: "unknown match type", rule: with(func(r *repository.AutoReply) { r.MatchType = "prefix" }), wantErr: true},
+		{name: "empty pattern", rule: with(func(r *repository.AutoReply) { r.Pattern = " " }), wantErr: true},
+		{name: "invalid regex", rule: with(func(r *repository.AutoReply) { r.MatchType, r.Pattern = repository.MatchRegex, "(" }), wantErr: true},
+		{name: "no reply", rule: with(func(r *repository.AutoReply) { r.ReplyText = "" }), wantErr: true},
+		{name: "media url scheme", rule: with(func(r *repository.AutoReply) { r.ReplyMediaURL = "file:///etc/passwd" }), wantErr: true},
+		{name: "media url localhost", rule: with(func(r *repository.AutoReply) { r.ReplyMediaURL = "http://localhost:8080/a.jpg" }), wantErr: true},
+		{name: "media url metadata", rule: with(func(r *repository.AutoReply) { r.ReplyMediaURL = "http://169.254.169.254/latest" }), wantErr: true},
+		{name: "media url private", rule: with(func(r *repository.AutoReply) { r.ReplyMediaURL = "http://[::ffff:10.0.0.1]/a.jpg" }), wantErr: true},
+		{name: "half window", rule: with(func(r *repository.AutoReply) { r.StartTime = "08:00" }), wantErr: true},
+		{name: "invalid time", rule: with(func(r *repository.AutoReply) { r.StartTime, r.EndTime = "8am", "17:00" }), wantErr: true},
+		{name: "invalid timezone", rule: with(func(r *repository.AutoReply) { r.Timezone = "Mars/Base" }), wantErr: true},
+		{name: "negative cooldown", rule: with(func(r *repository.AutoReply) { r.Cooldown = -1 }), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAutoReply(tt.rule)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("ValidateAutoReply = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidAutoReply) {
+				t.Fatalf("err = %v, want ErrInvalidAutoReply", err)
+			}
+		})
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{address: "8.8.8.8", want: true},
+		{address: "2606:4700:4700::1111", want: true},
+		{address: "127.0.0.1", want: false},
+		{address: "::1", want: false},
+		{address: "10.1.2.3", want: false},
+		{address: "172.16.0.1", want: false},
+		{address: "192.168.1.1", want: false},
+		{address: "169.254.169.254", want: false},
+		{address: "100.64.0.1", want: false},
+		{address: "0.0.0.0", want: false},
+		{address: "fd00::1", want: false},
+		{address: "fe80::1", want: false},
+		{address: "::ffff:127.0.0.1", want: false},
+		{address: "224.0.0.1", want: false},
+	}
+	for _, tt := range tests {
+		if got := isPublicAddress(netip.MustParseAddr(tt.address)); got != tt.want {
+			t.Fatalf("isPublicAddress(%s) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestFetchReplyMediaRefusesInternalAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+
+	if _, err := fetchReplyMedia(internal.URL); !errors.Is(err, errInternalAddress) {
+		t.Fatalf("fetchReplyMedia = %v, want errInternalAddress", err)
+	}
+}
+
+func TestAutoReplyPatternCache(t *testing.T) {
+	ch := CommandHandler{AutoReplies: repository.NewAutoReplyRepository(newTestDB(t))}
+	sender := types.NewJID("6281", types.DefaultUserServer)
+
+	rule, err := ch.CreateAutoReply(sender, repository.AutoReply{MatchType: repository.MatchRegex, Pattern: `^harga$`, ReplyText: "cek katalog"})
+	if err != nil {
+		t.Fatalf("CreateAutoReply: %v", err)
+	}
+	if !matchAutoReply(rule, "HARGA") {
+		t.Fatalf("rule does not match before the update")
+	}
+
+	rule.Pattern = `^ongkir$`
+	if rule, err = ch.UpdateAutoReply(sender, rule.ID, rule); err != nil {
+		t.Fatalf("UpdateAutoReply: %v", err)
+	}
+	if matchAutoReply(rule, "harga") || !matchAutoReply(rule, "ongkir") {
+		t.Fatalf("rule matches with the pattern before the update")
+	}
+
+	if err = ch.DeleteAutoReply(sender, rule.ID); err != nil {
+		t.Fatalf("DeleteAutoReply: %v", err)
+	}
+	if _, ok := autoReplyPatterns.Load(rule.ID); ok {
+		t.Fatalf("pattern of the deleted rule is still cached")
+	}
+}
@@ -0,0 +1,115 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+
+	"whatsapp_multi_session_general/backup"
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// BackupSessions exports the keys of the given senders (every stored session when empty) into an archive
+// encrypted with the passphrase.
+func (ch CommandHandler) BackupSessions(users []string, passphrase string) (data []byte, exported []string, err error) {
+	devices, err := ch.Container.GetAllDevices()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deviceJIDs := make(map[string]string, len(devices))
+	for _, device := range devices {
+		if device.ID != nil {
+			deviceJIDs[device.ID.User] = device.ID.String()
+		}
+	}
+
+	var jids []string
+	if len(users) == 0 {
+		for user, jid := range deviceJIDs {
+			jids = append(jids, jid)
+			exported = append(exported, user)
+		}
+	} else {
+		for _, user := range users {
+			jid, ok := deviceJIDs[user]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %s", session.ErrSessionNotFound, user)
+			}
+			jids = append(jids, jid)
+			exported = append(exported, user)
+		}
+	}
+	if len(jids) == 0 {
+		return nil, nil, session.ErrSessionNotFound
+	}
+
+	archive, err := backup.Export(ch.DB, jids)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = backup.Encrypt(archive, passphrase)
+	return data, exported, err
+}
+
+// RestoreSessions imports an archive created by BackupSessions, the running sessions of the same numbers
+// are stopped first, and the restored sessions are connected when connect is true.
+func (ch CommandHandler) RestoreSessions(data []byte, passphrase string, connect bool) (restored []string, err error) {
+	archive, err := backup.Decrypt(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dump := range archive.Sessions {
+		jid, errParse := types.ParseJID(dump.JID)
+		if errParse != nil {
+			return restored, fmt.Errorf("invalid jid %s on backup: %w", dump.JID, errParse)
+		}
+
+		// the running client keeps its keys in memory and would overwrite the restored ones
+		if client, ok := ch.Sessions.Get(jid.User); ok {
+			ch.supervisors.stop(client)
+			client.Disconnect()
+			ch.Sessions.CompareAndDelete(jid.User, client)
+		}
+
+		_, err = backup.Import(ch.DB, backup.Archive{Version: archive.Version, Sessions: []backup.SessionDump{dump}})
+		if err != nil {
+			return restored, err
+		}
+		restored = append(restored, jid.User)
+
+		if connect {
+			if errConnect := ch.ConnectDevice(jid.User); errConnect != nil {
+				fmt.Printf("err ConnectDevice on restore %s : %v \n", jid.User, errConnect)
+			}
+		}
+	}
+	return restored, nil
+}
+
+// ConnectDevice connects the stored device of the user in the background with its supervisor.
+func (ch CommandHandler) ConnectDevice(user string) error {
+	if client, ok := ch.Sessions.Get(user); ok && client.IsLoggedIn() {
+		return nil
+	}
+
+	device, err := ch.storedDevice(user)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return session.ErrSessionNotFound
+	}
+
+	client := ch.newClient(user, device)
+	ch.Sessions.Put(user, client)
+
+	sup, ok := ch.supervisors.get(client)
+	if !ok {
+		return errors.New("client is not supervised")
+	}
+	sup.start(errors.New("connect device"), false)
+	return nil
+}
@@ -0,0 +1,187 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/cronjob/crontab"
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	holidayLayout = "2006-01-02"
+	// maxClosedLookback bounds the search of the start of a closed window, it is searched one day at a time,
+	// a schedule that is never open starts a new window after it
+	maxClosedLookback = 31 * 24 * time.Hour
+)
+
+var ErrInvalidBusinessHours = errors.New("invalid business hours")
+
+// businessSchedule is the parsed business hours of a session.
+type businessSchedule struct {
+	location *time.Location
+	hours    []crontab.Schedule
+	holidays map[string]struct{}
+}
+
+// parseBusinessHours parses the schedules and the holidays of the business hours, the error wraps ErrInvalidBusinessHours.
+func parseBusinessHours(hours repository.BusinessHours) (businessSchedule, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidBusinessHours, fmt.Sprintf(format, args...))
+	}
+
+	var schedule businessSchedule
+	location, err := time.LoadLocation(hours.Timezone)
+	if err != nil {
+		return schedule, invalid("timezone: %v", err)
+	}
+	schedule.location = location
+
+	if len(hours.Hours) == 0 {
+		return schedule, invalid("hours should have at least one schedule")
+	}
+	for _, value := range hours.Hours {
+		parsed, err := crontab.ParseSchedule(strings.TrimSpace(value))
+		if err != nil {
+			return schedule, invalid("hours %q: %v", value, err)
+		}
+		schedule.hours = append(schedule.hours, parsed)
+	}
+
+	schedule.holidays = make(map[string]struct{}, len(hours.Holidays))
+	for _, value := range hours.Holidays {
+		day, err := time.Parse(holidayLayout, strings.TrimSpace(value))
+		if err != nil {
+			return schedule, invalid("holiday %q should be YYYY-MM-DD", value)
+		}
+		schedule.holidays[day.Format(holidayLayout)] = struct{}{}
+	}
+	return schedule, nil
+}
+
+// isOpen reports whether the minute of t is within the business hours.
+func (s businessSchedule) isOpen(t time.Time) bool {
+	local := t.In(s.location)
+	if _, ok := s.holidays[local.Format(holidayLayout)]; ok {
+		return false
+	}
+	for _, hours := range s.hours {
+		if hours.Matches(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// closedSince returns the start of the closed window that t is in, it is the minute after the last open minute.
+// the days are searched backwards for their last open minute, a schedule that is never open starts a new window
+// after maxClosedLookback.
+func (s businessSchedule) closedSince(t time.Time) time.Time {
+	minute := t.Truncate(time.Minute)
+	oldest := minute.Add(-maxClosedLookback)
+
+	upTo := minute.Add(-time.Minute).In(s.location)
+	for upTo.After(oldest) {
+		var last time.Time
+		if _, holiday := s.holidays[upTo.Format(holidayLayout)]; !holiday {
+			for _, hours := range s.hours {
+				if at, ok := hours.LastOnDay(upTo); ok && at.After(last) {
+					last = at
+				}
+			}
+		}
+		if !last.IsZero() {
+			if last.Before(oldest) {
+				return oldest
+			}
+			return last.Add(time.Minute)
+		}
+		// the last minute of the previous day
+		upTo = time.Date(upTo.Year(), upTo.Month(), upTo.Day(), 0, 0, 0, 0, s.location).Add(-time.Minute)
+	}
+	return oldest
+}
+
+// GetBusinessHours returns the business hours of the sender.
+func (ch CommandHandler) GetBusinessHours(sender types.JID) (repository.BusinessHours, error) {
+	return ch.BusinessHours.Get(sender.User)
+}
+
+// PutBusinessHours validates and stores the business hours of the sender.
+func (ch CommandHandler) PutBusinessHours(sender types.JID, hours repository.BusinessHours) (repository.BusinessHours, error) {
+	if _, err := parseBusinessHours(hours); err != nil {
+		return hours, err
+	}
+	if strings.TrimSpace(hours.AwayMessage) == "" {
+		return hours, fmt.Errorf("%w: awayMessage should be filled", ErrInvalidBusinessHours)
+	}
+
+	hours.Session = sender.User
+	if err := ch.BusinessHours.Put(hours); err != nil {
+		return hours, err
+	}
+	return ch.BusinessHours.Get(sender.User)
+}
+
+// DeleteBusinessHours removes the business hours of the sender, the away message is not sent anymore.
+func (ch CommandHandler) DeleteBusinessHours(sender types.JID) error {
+	found, err := ch.BusinessHours.Delete(sender.User)
+	if err == nil && !found {
+		err = repository.ErrNotFound
+	}
+	return err
+}
+
+// awayReply sends the away message of the session to a contact that writes outside of the business hours,
+// once per contact for every closed window. Group chats are not answered.
+func (ch CommandHandler) awayReply(user string, evt *events.Message) {
+	if !config.Conf.BusinessHours.Enable || ch.BusinessHours == nil || evt.Info.IsGroup || !isAnswerable(evt) {
+		return
+	}
+	if content := parseMessageContent(evt.Message); content.Type == "" || content.Type == MessageTypeReaction {
+		return
+	}
+
+	hours, err := ch.BusinessHours.Get(user)
+	if errors.Is(err, repository.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		fmt.Printf("err BusinessHours.Get %s : %v \n", user, err)
+		return
+	}
+	if !hours.Enabled || ch.optedOut(user, evt.Info.Sender) {
+		return
+	}
+
+	schedule, err := parseBusinessHours(hours)
+	if err != nil {
+		fmt.Printf("err business hours of %s : %v \n", user, err)
+		return
+	}
+	now := time.Now()
+	if schedule.isOpen(now) {
+		return
+	}
+
+	contact := evt.Info.Sender.ToNonAD().String()
+	claimed, err := ch.BusinessHours.ClaimAway(user, contact, schedule.closedSince(now), now)
+	if err != nil {
+		fmt.Printf("err BusinessHours.ClaimAway %s : %v \n", contact, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	sender := types.NewJID(user, types.DefaultUserServer)
+	if _, err = ch.HandleSendNewTextMessage(sender, hours.AwayMessage, evt.Info.Chat.ToNonAD().String()); err != nil {
+		fmt.Printf("err away message to %s : %v \n", contact, err)
+	}
+}
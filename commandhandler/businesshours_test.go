@@ -0,0 +1,87 @@
+package commandhandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestParseBusinessHours(t *testing.T) {
+	tests := []struct {
+		name    string
+		hours   repository.BusinessHours
+		wantErr bool
+	}{
+		{name: "valid", hours: repository.BusinessHours{Timezone: "Asia/Jakarta", Hours: []string{"* 8-16 * * 1-5"}, Holidays: []string{"2026-12-25"}}},
+		{name: "unknown timezone", hours: repository.BusinessHours{Timezone: "Mars/Base", Hours: []string{"* 8-16 * * 1-5"}}, wantErr: true},
+		{name: "no hours", hours: repository.BusinessHours{Timezone: "UTC"}, wantErr: true},
+		{name: "invalid hours", hours: repository.BusinessHours{Timezone: "UTC", Hours: []string{"* 25 * * *"}}, wantErr: true},
+		{name: "invalid holiday", hours: repository.BusinessHours{Timezone: "UTC", Hours: []string{"* * * * *"}, Holidays: []string{"25-12-2026"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseBusinessHours(tt.hours)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidBusinessHours) {
+				t.Fatalf("err %v does not wrap ErrInvalidBusinessHours", err)
+			}
+		})
+	}
+}
+
+// closedSinceByMinute is the reference of closedSince, it walks back one minute at a time.
+func closedSinceByMinute(s businessSchedule, t time.Time) time.Time {
+	minute := t.Truncate(time.Minute)
+	oldest := minute.Add(-maxClosedLookback)
+	for at := minute.Add(-time.Minute); at.After(oldest); at = at.Add(-time.Minute) {
+		if s.isOpen(at) {
+			return at.Add(time.Minute)
+		}
+	}
+	return oldest
+}
+
+func TestClosedSince(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+
+	schedules := []repository.BusinessHours{
+		// weekdays 08:00-16:59 and saturday morning
+		{Timezone: "Asia/Jakarta", Hours: []string{"* 8-16 * * 1-5", "0-30 9-11 * * 6"}},
+		// with a holiday on a friday
+		{Timezone: "Asia/Jakarta", Hours: []string{"* 8-16 * * 1-5"}, Holidays: []string{"2026-10-16"}},
+		// open on the 1st of every month only
+		{Timezone: "UTC", Hours: []string{"*/15 10 1 * *"}},
+		// never open in the lookback
+		{Timezone: "UTC", Hours: []string{"* * 1 2 *"}},
+	}
+	times := []time.Time{
+		time.Date(2026, 10, 17, 7, 0, 30, 0, jakarta),  // saturday before the saturday hours
+		time.Date(2026, 10, 17, 12, 0, 0, 0, jakarta),  // saturday after the saturday hours
+		time.Date(2026, 10, 19, 7, 59, 0, 0, jakarta),  // monday before opening
+		time.Date(2026, 10, 19, 17, 0, 0, 0, jakarta),  // monday right after closing
+		time.Date(2026, 10, 20, 23, 30, 0, 0, jakarta), // tuesday night
+	}
+
+	for _, hours := range schedules {
+		schedule, err := parseBusinessHours(hours)
+		if err != nil {
+			t.Fatalf("parseBusinessHours %v: %v", hours.Hours, err)
+		}
+		for _, at := range times {
+			if schedule.isOpen(at) {
+				continue
+			}
+			want := closedSinceByMinute(schedule, at)
+			if got := schedule.closedSince(at); !got.Equal(want) {
+				t.Errorf("%v at %s: closedSince = %s, want %s", hours.Hours, at, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// maxRejectAge is how long after the offer a call is still rejected, an older call (e.g. received after a reconnect)
+// is not ringing anymore and is only recorded.
+const maxRejectAge = time.Minute
+
+// callEventHandler records the incoming calls of the session, rejects them by the call settings of the session
+// and publishes them.
+func (ch CommandHandler) callEventHandler(requestedUser string, client *whatsmeow.Client) whatsmeow.EventHandler {
+	return func(evt interface{}) {
+		switch v := evt.(type) {
+		case *events.CallOffer:
+			call := repository.Call{
+				CallID:      v.CallID,
+				Caller:      v.From.ToNonAD().String(),
+				CallCreator: v.CallCreator.ToNonAD().String(),
+				Platform:    v.RemotePlatform,
+				Timestamp:   v.Timestamp,
+			}
+			if v.Data != nil {
+				_, call.IsVideo = v.Data.GetOptionalChildByTag("video")
+			}
+			// rejecting and replying wait for the server, they are done outside of the event loop
+			go ch.handleCall(ch.sessionKey(requestedUser, client), client, v.From, call)
+		case *events.CallOfferNotice:
+			// a group call can not be rejected for the group, it is only recorded
+			call := repository.Call{
+				CallID:      v.CallID,
+				Caller:      v.From.ToNonAD().String(),
+				CallCreator: v.CallCreator.ToNonAD().String(),
+				IsVideo:     v.Media == "video",
+				IsGroup:     v.Type == "group",
+				Timestamp:   v.Timestamp,
+			}
+			go ch.handleCall(ch.sessionKey(requestedUser, client), client, v.From, call)
+		}
+	}
+}
+
+func (ch CommandHandler) handleCall(user string, client *whatsmeow.Client, from types.JID, call repository.Call) {
+	call.Session = user
+	if call.Timestamp.IsZero() {
+		call.Timestamp = time.Now()
+	}
+
+	settings, err := ch.Calls.GetSettings(user)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		fmt.Printf("err Calls.GetSettings %s : %v \n", user, err)
+	}
+
+	if settings.Reject && !call.IsGroup && time.Since(call.Timestamp) < maxRejectAge {
+		if err = rejectCall(client, from, call.CallID); err != nil {
+			call.RejectError = err.Error()
+			fmt.Printf("err rejectCall %s : %v \n", call.CallID, err)
+		} else {
+			call.Rejected = true
+		}
+	}
+
+	if call.Rejected && settings.Reply != "" && !ch.optedOut(user, from) {
+		sender := types.NewJID(user, types.DefaultUserServer)
+		if _, err = ch.HandleSendNewTextMessage(sender, settings.Reply, from.ToNonAD().String()); err != nil {
+			fmt.Printf("err call reply to %s : %v \n", call.Caller, err)
+		} else {
+			call.Replied = true
+		}
+	}
+
+	if err = ch.Calls.CreateCall(call); err != nil {
+		fmt.Printf("err Calls.CreateCall %s : %v \n", call.CallID, err)
+	}
+	ch.Publish(user, primitive.EventCall, primitive.CallEvent{
+		CallID:      call.CallID,
+		From:        call.Caller,
+		CallCreator: call.CallCreator,
+		IsVideo:     call.IsVideo,
+		IsGroup:     call.IsGroup,
+		Platform:    call.Platform,
+		Rejected:    call.Rejected,
+		RejectError: call.RejectError,
+		Replied:     call.Replied,
+		Timestamp:   call.Timestamp,
+	})
+}
+
+// rejectCall declines an incoming call, the whatsmeow version in use has no RejectCall so the reject node
+// is sent the same way newer versions do.
+func rejectCall(client *whatsmeow.Client, from types.JID, callID string) error {
+	if client.Store.ID == nil {
+		return whatsmeow.ErrNotLoggedIn
+	}
+	ownID, from := client.Store.ID.ToNonAD(), from.ToNonAD()
+	return client.DangerousInternals().SendNode(waBinary.Node{
+		Tag:   "call",
+		Attrs: waBinary.Attrs{"id": client.GenerateMessageID(), "from": ownID, "to": from},
+		Content: []waBinary.Node{{
+			Tag:   "reject",
+			Attrs: waBinary.Attrs{"call-id": callID, "call-creator": from, "count": "0"},
+		}},
+	})
+}
+
+// GetCallSettings returns the call settings of the sender.
+func (ch CommandHandler) GetCallSettings(sender types.JID) (repository.CallSettings, error) {
+	return ch.Calls.GetSettings(sender.User)
+}
+
+// PutCallSettings stores the call settings of the sender.
+func (ch CommandHandler) PutCallSettings(sender types.JID, settings repository.CallSettings) (repository.CallSettings, error) {
+	settings.Session = sender.User
+	if err := ch.Calls.PutSettings(settings); err != nil {
+		return settings, err
+	}
+	return ch.Calls.GetSettings(sender.User)
+}
+
+// DeleteCallSettings removes the call settings of the sender, the calls are not rejected anymore.
+func (ch CommandHandler) DeleteCallSettings(sender types.JID) error {
+	found, err := ch.Calls.DeleteSettings(sender.User)
+	if err == nil && !found {
+		err = repository.ErrNotFound
+	}
+	return err
+}
+
+// ListCalls returns a page of the calls received by the sender, newest first.
+func (ch CommandHandler) ListCalls(sender types.JID, limit, offset int) ([]repository.Call, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	return ch.Calls.ListCalls(sender.User, historyLimit(limit), offset)
+}
@@ -0,0 +1,158 @@
+package commandhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp_multi_session_general/chatbot"
+	"whatsapp_multi_session_general/config"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const defaultBotTimeout = 10 * time.Second
+
+// newBotRouter creates the command router of the config, the commands of the config and the unknown commands
+// are answered by their http callback.
+func newBotRouter() *chatbot.Router {
+	router := chatbot.NewRouter(config.Conf.Bot.Prefix)
+	for _, command := range config.Conf.Bot.Commands {
+		if command.Name == "" || command.URL == "" {
+			continue
+		}
+		router.Register(command.Name, command.Description, chatbot.NewCallback(command.URL, config.Conf.SignString, config.Conf.Bot.Timeout))
+	}
+	if config.Conf.Bot.CallbackURL != "" {
+		router.SetFallback(chatbot.NewCallback(config.Conf.Bot.CallbackURL, config.Conf.SignString, config.Conf.Bot.Timeout))
+	}
+	return router
+}
+
+// registerBuiltinCommands adds the commands every bot answers, a command of the config with the same name replaces it.
+func (ch CommandHandler) registerBuiltinCommands() {
+	registered := make(map[string]bool)
+	for _, command := range ch.Bot.Commands() {
+		registered[command.Name] = true
+	}
+	builtin := func(name, description string, handler chatbot.Handler) {
+		if !registered[name] {
+			ch.Bot.Register(name, description, handler)
+		}
+	}
+
+	builtin("help", "show the available commands", ch.helpCommand)
+	builtin("status", "show the status of this number", ch.statusCommand)
+	builtin("stop", "stop the automatic replies", ch.stopCommand)
+	builtin("start", "receive the automatic replies again", ch.startCommand)
+}
+
+func (ch CommandHandler) helpCommand(ctx context.Context, cmd chatbot.Command) (string, error) {
+	var reply strings.Builder
+	reply.WriteString("Available commands:")
+	for _, command := range ch.Bot.Commands() {
+		reply.WriteString("\n" + ch.Bot.Prefix() + command.Name)
+		if command.Description != "" {
+			reply.WriteString(" - " + command.Description)
+		}
+	}
+	return reply.String(), nil
+}
+
+func (ch CommandHandler) statusCommand(ctx context.Context, cmd chatbot.Command) (string, error) {
+	status := ch.SessionStatus(cmd.Session)
+	reply := fmt.Sprintf("%s is %s", cmd.Session, status.State)
+	if !status.UpdatedAt.IsZero() {
+		reply += fmt.Sprintf(" since %s", status.UpdatedAt.Format(time.RFC1123))
+	}
+	return reply, nil
+}
+
+func (ch CommandHandler) stopCommand(ctx context.Context, cmd chatbot.Command) (string, error) {
+	if err := ch.OptOuts.Add(cmd.Session, cmd.Sender); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("You will not receive automatic replies anymore, send %sstart to receive them again.", ch.Bot.Prefix()), nil
+}
+
+func (ch CommandHandler) startCommand(ctx context.Context, cmd chatbot.Command) (string, error) {
+	if _, err := ch.OptOuts.Remove(cmd.Session, cmd.Sender); err != nil {
+		return "", err
+	}
+	return "You will receive automatic replies again.", nil
+}
+
+// botCommand dispatches an inbound text that starts with the prefix to its command and sends the reply back,
+// it reports whether the message was a command so it is not answered by the other automatic replies.
+func (ch CommandHandler) botCommand(user string, evt *events.Message) bool {
+	if !config.Conf.Bot.Enable || ch.Bot == nil || !isAnswerable(evt) {
+		return false
+	}
+	content := parseMessageContent(evt.Message)
+	if content.Type != MessageTypeText {
+		return false
+	}
+	cmd, ok := ch.Bot.Parse(content.Text)
+	if !ok {
+		return false
+	}
+
+	cmd.Session = user
+	cmd.Chat = evt.Info.Chat.ToNonAD().String()
+	cmd.Sender = evt.Info.Sender.ToNonAD().String()
+	cmd.PushName = evt.Info.PushName
+	cmd.MessageID = evt.Info.ID
+	cmd.IsGroup = evt.Info.IsGroup
+
+	timeout := config.Conf.Bot.Timeout
+	if timeout <= 0 {
+		timeout = defaultBotTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	reply, err := ch.Bot.Dispatch(ctx, cmd)
+	switch {
+	case errors.Is(err, chatbot.ErrUnknownCommand):
+		reply = fmt.Sprintf("Unknown command %s%s, send %shelp for the available commands.", ch.Bot.Prefix(), cmd.Name, ch.Bot.Prefix())
+	case err != nil:
+		fmt.Printf("err bot command %s of %s : %v \n", cmd.Name, user, err)
+		reply = fmt.Sprintf("Sorry, %s%s can not be processed right now.", ch.Bot.Prefix(), cmd.Name)
+	}
+	if reply == "" {
+		return true
+	}
+
+	sender := types.NewJID(user, types.DefaultUserServer)
+	if _, err = ch.HandleSendNewTextMessage(sender, reply, cmd.Chat); err != nil {
+		fmt.Printf("err bot reply %s of %s : %v \n", cmd.Name, user, err)
+	}
+	return true
+}
+
+// isAnswerable reports whether the message may get an automatic reply, messages from our own devices,
+// status updates and messages older than the max age (e.g. the backlog of a reconnect) are not answered.
+func isAnswerable(evt *events.Message) bool {
+	if evt.Info.IsFromMe || evt.Info.Chat == types.StatusBroadcastJID {
+		return false
+	}
+	if maxAge := config.Conf.AutoReply.MaxAge; maxAge > 0 && time.Since(evt.Info.Timestamp) > maxAge {
+		return false
+	}
+	return true
+}
+
+// optedOut reports whether the contact stopped the automatic replies of the session.
+func (ch CommandHandler) optedOut(user string, contact types.JID) bool {
+	if ch.OptOuts == nil {
+		return false
+	}
+	optedOut, err := ch.OptOuts.Has(user, contact.ToNonAD().String())
+	if err != nil {
+		fmt.Printf("err OptOuts.Has %s : %v \n", user, err)
+	}
+	return optedOut
+}
@@ -0,0 +1,141 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow"
+)
+
+const (
+	// defaultPairingExpiry is used when config pairing.expiry is not set,
+	// whatsapp stops emitting qr codes after around 160 seconds
+	defaultPairingExpiry = 3 * time.Minute
+)
+
+// pendingPairing is a client that is connected to pair a sender but is not paired yet.
+type pendingPairing struct {
+	client    *whatsmeow.Client
+	startedAt time.Time
+	expiresAt time.Time
+}
+
+// pairingTracker keeps the pending pairing of every sender, so abandoned pairings can be cleaned up.
+type pairingTracker struct {
+	mu      sync.Mutex
+	pending map[string]*pendingPairing
+}
+
+func newPairingTracker() *pairingTracker {
+	return &pairingTracker{
+		pending: make(map[string]*pendingPairing),
+	}
+}
+
+// add tracks the pairing and returns the previous pending pairing of the user, if any.
+func (t *pairingTracker) add(user string, pairing *pendingPairing) (previous *pendingPairing) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous = t.pending[user]
+	t.pending[user] = pairing
+	return previous
+}
+
+// remove stops tracking the pairing of the user if it still belongs to the client.
+func (t *pairingTracker) remove(user string, client *whatsmeow.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if pairing, ok := t.pending[user]; ok && pairing.client == client {
+		delete(t.pending, user)
+	}
+}
+
+// isPending reports whether the client is the pending pairing of the user.
+func (t *pairingTracker) isPending(user string, client *whatsmeow.Client) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pairing, ok := t.pending[user]
+	return ok && pairing.client == client
+}
+
+// expired removes and returns every pairing that is expired at the given time.
+func (t *pairingTracker) expired(now time.Time) map[string]*pendingPairing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make(map[string]*pendingPairing)
+	for user, pairing := range t.pending {
+		if now.After(pairing.expiresAt) {
+			result[user] = pairing
+			delete(t.pending, user)
+		}
+	}
+	return result
+}
+
+// CleanupResult is the summary of a cleanup run.
+type CleanupResult struct {
+	ExpiredPairings []string `json:"expiredPairings"`
+	OrphanClients   []string `json:"orphanClients"`
+	RemovedDevices  []string `json:"removedDevices"`
+}
+
+// CleanupDevices discards the expired pairings, unregisters clients that lost their device (e.g. after a logout)
+// and removes the logged-out devices that are still in the sqlstore.
+func (ch CommandHandler) CleanupDevices() (result CleanupResult, err error) {
+	result = CleanupResult{
+		ExpiredPairings: []string{},
+		OrphanClients:   []string{},
+		RemovedDevices:  []string{},
+	}
+
+	// pairing that is never finished, the device is not saved yet so dropping the client is enough
+	for user, pairing := range ch.pairings.expired(time.Now()) {
+		ch.discardPairing(user, pairing.client, errors.New("pairing is expired"))
+		result.ExpiredPairings = append(result.ExpiredPairings, user)
+	}
+
+	// registered client without a device that is not pairing anymore
+	for user, client := range ch.Sessions.List() {
+		if client.Store.ID != nil || ch.pairings.isPending(user, client) {
+			continue
+		}
+		client.Disconnect()
+		if ch.Sessions.CompareAndDelete(user, client) {
+			result.OrphanClients = append(result.OrphanClients, user)
+		}
+	}
+
+	// device that is logged out but could not be deleted from the store when the logout happened
+	devices, err := ch.Container.GetAllDevices()
+	if err != nil {
+		return result, err
+	}
+	for _, device := range devices {
+		if device.ID == nil {
+			continue
+		}
+		user := device.ID.User
+		status, ok := ch.Sessions.Status(user)
+		if !ok || status.State != session.StateLoggedOut {
+			continue
+		}
+
+		if client, ok := ch.Sessions.Get(user); ok {
+			client.Disconnect()
+			ch.Sessions.CompareAndDelete(user, client)
+		}
+		errDelete := device.Delete()
+		if errDelete != nil {
+			fmt.Printf("err device.Delete on cleanup %s : %v \n", user, errDelete)
+			err = errDelete
+			continue
+		}
+		result.RemovedDevices = append(result.RemovedDevices, user)
+	}
+
+	return result, err
+}
@@ -2,18 +2,22 @@ package commandhandler
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
+	"whatsapp_multi_session_general/chatbot"
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/media"
 	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/repository"
+	"whatsapp_multi_session_general/session"
 
-	"github.com/mdp/qrterminal/v3"
 	"github.com/skip2/go-qrcode"
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
@@ -21,14 +25,9 @@ import (
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
-	waLog "go.mau.fi/whatsmeow/util/log"
 	"google.golang.org/protobuf/proto"
 )
 
-var (
-	Clients = make(map[string]*whatsmeow.Client)
-)
-
 type Message struct {
 	MessageID string
 	Jid       string
@@ -40,21 +39,68 @@ type Message struct {
 
 type CommandHandler struct {
 	Container *sqlstore.Container
+	DB        *sql.DB
+	Sessions  *session.Registry
+	Messages  *repository.MessageRepository
+	// AutoReplies are the keyword rules that answer the incoming messages
+	AutoReplies *repository.AutoReplyRepository
+	// BusinessHours are the opening schedules of the sessions, the away message is sent outside of them
+	BusinessHours *repository.BusinessHoursRepository
+	// Bot routes the inbound commands to their handlers, OptOuts are the contacts that stopped the automatic replies
+	Bot     *chatbot.Router
+	OptOuts *repository.OptOutRepository
+	// Calls are the call settings of the sessions and the received calls
+	Calls *repository.CallRepository
+
+	// MediaStore keeps the content of the downloaded inbound media, MediaFiles its metadata
+	MediaStore media.Store
+	MediaFiles *repository.MediaRepository
+
+	pairings    *pairingTracker
+	supervisors *supervisorSet
+	mediaSlots  chan struct{}
 }
 
-func NewCommandHandler(container *sqlstore.Container) CommandHandler {
-	return CommandHandler{
-		Container: container,
-	}
+func NewCommandHandler(container *sqlstore.Container, db *sql.DB, sessions *session.Registry) CommandHandler {
+	ch := CommandHandler{
+		Container:     container,
+		DB:            db,
+		Sessions:      sessions,
+		Messages:      repository.NewMessageRepository(db),
+		AutoReplies:   repository.NewAutoReplyRepository(db),
+		BusinessHours: repository.NewBusinessHoursRepository(db),
+		Bot:           newBotRouter(),
+		OptOuts:       repository.NewOptOutRepository(db),
+		Calls:         repository.NewCallRepository(db),
+		MediaStore:    media.NewLocalStore(config.Conf.Media.Dir),
+		MediaFiles:    repository.NewMediaRepository(db),
+		pairings:      newPairingTracker(),
+		supervisors:   newSupervisorSet(),
+		mediaSlots:    newMediaSlots(),
+	}
+
+	ch.registerBuiltinCommands()
+
+	// a removed session is not reconnected anymore, unless the client is still registered under another key
+	sessions.OnDelete(func(user string, client *whatsmeow.Client) {
+		if _, ok := sessions.UserOf(client); !ok {
+			ch.supervisors.stop(client)
+		}
+	})
+	return ch
 }
 
 func (ch CommandHandler) NewHandleSendPresence(sender types.JID) (err error) {
-	err = Clients[sender.User].SendPresence(types.PresenceAvailable)
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return session.ErrSessionNotFound
+	}
+
+	err = client.SendPresence(types.PresenceAvailable)
 	if err != nil {
 		fmt.Errorf("Error sending presence: %v", err)
 		return
 	}
-	Clients[sender.User].AddEventHandler(EventHandler)
 	return nil
 }
 
@@ -65,7 +111,12 @@ func (ch CommandHandler) NewHandleCheckUser(sender types.JID, args []string) (re
 		return nil
 	}
 
-	resp, err := Clients[sender.User].IsOnWhatsApp(args)
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return nil
+	}
+
+	resp, err := client.IsOnWhatsApp(args)
 	if err != nil {
 		fmt.Errorf("Failed to check if users are on WhatsApp: %v", err)
 		return nil
@@ -84,37 +135,49 @@ func (ch CommandHandler) NewHandleCheckUser(sender types.JID, args []string) (re
 }
 
 func (ch CommandHandler) HandleSendNewTextMessage(sender types.JID, textMsg string, jid string) (messageID string, err error) {
+	return ch.HandleSendTextMessage(sender, textMsg, jid, TextOptions{})
+}
+
+// HandleSendTextMessage sends a text message, with options it is sent as an extended text that quotes a message
+// of the chat and mentions contacts.
+func (ch CommandHandler) HandleSendTextMessage(sender types.JID, textMsg string, jid string, opts TextOptions) (messageID string, err error) {
 	recipient, ok := ParseJID(jid)
 	if !ok {
 		return
 	}
 
-	msg := &waProto.Message{
-		Conversation: proto.String(textMsg),
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return "", session.ErrSessionNotFound
+	}
+
+	msg, err := ch.createTextMessage(sender, recipient, textMsg, opts)
+	if err != nil {
+		return "", err
 	}
 
-	err = Clients[sender.User].SendPresence(types.PresenceAvailable)
+	err = client.SendPresence(types.PresenceAvailable)
 	if err != nil {
 		fmt.Errorf("Error sending presence: %v", err)
 		return
 	}
 
-	fmt.Printf("Sending message to %s: %s", recipient, msg.GetConversation())
+	fmt.Printf("Sending message to %s: %s", recipient, textMsg)
 
 	//set message id from std lib whatsmeo
-	messageID = Clients[sender.User].GenerateMessageID()
+	messageID = client.GenerateMessageID()
 
 	fmt.Printf("request messageID from sendRequestExtra is : %v ", messageID)
 
-	resp, err := Clients[sender.User].SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+	ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: "text", Body: textMsg})
+	resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+	ch.logOutboundResult(sender.User, messageID, resp, err)
 	if err != nil {
 		fmt.Errorf("Error sending message: %v", err)
 		return
 	}
 
-	Clients[sender.User].AddEventHandler(EventHandler)
-
-	err = Clients[sender.User].MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+	err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
 	if err != nil {
 		fmt.Errorf("Error sending MarkRead: %v", err)
 		return
@@ -127,6 +190,11 @@ func (ch CommandHandler) HandleSendNewTextMessage(sender types.JID, textMsg stri
 }
 
 func (ch CommandHandler) HandleSendNewTextMessageBulk(sender types.JID, textMsg string, jids []string) {
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return
+	}
+
 	var wg sync.WaitGroup
 	for _, jid := range jids {
 		wg.Add(1)
@@ -138,7 +206,7 @@ func (ch CommandHandler) HandleSendNewTextMessageBulk(sender types.JID, textMsg
 				return
 			}
 
-			err := Clients[sender.User].SendPresence(types.PresenceAvailable)
+			err := client.SendPresence(types.PresenceAvailable)
 			if err != nil {
 				fmt.Errorf("Error sending presence: %v", err)
 				return
@@ -150,13 +218,16 @@ func (ch CommandHandler) HandleSendNewTextMessageBulk(sender types.JID, textMsg
 
 			fmt.Printf("Sending message to %s: %s", recipient, msg.GetConversation())
 
-			resp, err := Clients[sender.User].SendMessage(context.Background(), recipient, msg)
+			messageID := client.GenerateMessageID()
+			ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: "text", Body: textMsg})
+			resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+			ch.logOutboundResult(sender.User, messageID, resp, err)
 			if err != nil {
 				fmt.Errorf("Error sending message: %v", err)
 				return
 			}
 
-			err = Clients[sender.User].MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+			err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
 			if err != nil {
 				fmt.Errorf("Error sending MarkRead: %v", err)
 				return
@@ -168,64 +239,24 @@ func (ch CommandHandler) HandleSendNewTextMessageBulk(sender types.JID, textMsg
 	wg.Wait()
 }
 
-func (ch CommandHandler) GetSingleQR(ctx context.Context, clients map[string]*whatsmeow.Client, senderJidTypes types.JID) (string, error) {
+func (ch CommandHandler) GetSingleQR(ctx context.Context, senderJidTypes types.JID) (string, error) {
 	device, err := ch.Container.GetFirstDevice()
 	if err != nil {
 		panic(err)
 	}
 
-	// Create a client for each device
-	clientLog := waLog.Stdout("Client", "DEBUG", true)
-	client := whatsmeow.NewClient(device, clientLog)
-	client.AddEventHandler(EventHandler)
-
-	// Connect the client synchronously
-	if client.Store.ID == nil {
-		qrChan, err := client.GetQRChannel(ctx)
-		if err != nil {
-			panic(err)
-		}
-		err = client.Connect()
-		if err != nil {
-			panic(err)
-		}
-
-		fmt.Println("Waiting for QR code or login event...")
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				fmt.Println("QR code:", evt.Code)
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				image, errGen := generateQRCode(evt.Code)
-				if errGen != nil {
-					// Log the error for debugging
-					fmt.Printf("Error generating QR code : %v", errGen)
-					return "", errGen
-				}
-				// Add the client to the map
-				clients[senderJidTypes.User] = client
-
-				return string(image), nil
-			} else {
-				fmt.Println("Login event:", evt.Event)
-			}
-		}
-	} else {
-		err := client.Connect()
-		if err != nil {
-			panic(err)
-		}
-		// Add the client to the map
-		clients[device.ID.User] = client
-		return "", nil
+	// the first device is already paired, connect it under its own number
+	if device.ID != nil {
+		return ch.GetSpecificQR(ctx, *device.ID)
 	}
-
-	return "", nil
+	return ch.GetSpecificQR(ctx, senderJidTypes)
 }
 
-func (ch CommandHandler) GetSpecificQR(ctx context.Context, clients map[string]*whatsmeow.Client, jid types.JID) (string, error) {
+// findDevice returns the stored device of the jid, or a new unsaved device when the jid has never been paired.
+func (ch CommandHandler) findDevice(jid types.JID) (*store.Device, error) {
 	devices, err := ch.Container.GetAllDevices()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	var device *store.Device
@@ -243,50 +274,30 @@ func (ch CommandHandler) GetSpecificQR(ctx context.Context, clients map[string]*
 	if device == nil || device.ID.User == "" {
 		device = ch.Container.NewDevice()
 	}
+	return device, nil
+}
 
-	clientLog := waLog.Stdout("Client", "DEBUG", true)
-	client := whatsmeow.NewClient(device, clientLog)
-	client.AddEventHandler(EventHandler)
+func (ch CommandHandler) GetSpecificQR(ctx context.Context, jid types.JID) (string, error) {
+	pairing, err := ch.StartPairing(ctx, jid)
+	if errors.Is(err, ErrAlreadyLogin) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
 
-	// Connect the client synchronously
-	if client.Store.ID == nil {
-		qrChan, errGetQr := client.GetQRChannel(ctx)
-		if errGetQr != nil {
-			panic(errGetQr)
-		}
-		err = client.Connect()
-		if err != nil {
-			panic(err)
-		}
+	code, err := firstQRCode(pairing)
+	if err != nil {
+		return "", err
+	}
 
-		fmt.Println("Waiting for QR code or login event...")
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				fmt.Println("QR code:", evt.Code)
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				// Add the client to the map
-				clients[jid.User] = client
-				image, errGenerateCode := generateQRCode(evt.Code)
-				if errGenerateCode != nil {
-					// Log the error for debugging
-					fmt.Println("Error generating QR code:", errGenerateCode)
-					return "", errGenerateCode
-				}
-				return string(image), nil
-			} else {
-				fmt.Println("Login event:", evt.Event)
-			}
-		}
-	} else {
-		err := client.Connect()
-		if err != nil {
-			panic(err)
-		}
-		// Add the client to the map
-		clients[jid.User] = client
-		return "", nil
+	image, errGenerateCode := generateQRCode(code)
+	if errGenerateCode != nil {
+		// Log the error for debugging
+		fmt.Println("Error generating QR code:", errGenerateCode)
+		return "", errGenerateCode
 	}
-	return "", nil
+	return string(image), nil
 }
 
 func createImageMessage(uploaded whatsmeow.UploadResponse, data *[]byte, captionMsg string) *waProto.Message {
@@ -370,7 +381,12 @@ func ValidateStringArrayAsStringArray(stringInput string) ([]string, error) {
 	return stringSlice, nil
 }
 
-func NewHandleSendImage(sender types.JID, JIDS []string, data []byte, captionMsg string) ([]Message, error) {
+func (ch CommandHandler) NewHandleSendImage(sender types.JID, JIDS []string, data []byte, captionMsg string) ([]Message, error) {
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var sliceM []Message
@@ -389,22 +405,28 @@ func NewHandleSendImage(sender types.JID, JIDS []string, data []byte, captionMsg
 				return
 			}
 
-			err := Clients[sender.User].SendPresence(types.PresenceAvailable)
+			err := client.SendPresence(types.PresenceAvailable)
 			if err != nil {
 				fmt.Errorf("Error sending presence: %v", err)
 				return
 			}
 
-			uploaded, err := Clients[sender.User].Upload(context.Background(), data, whatsmeow.MediaImage)
+			messageID := client.GenerateMessageID()
+			ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: "image", Body: captionMsg})
+
+			uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
 			if err != nil {
+				ch.logOutboundResult(sender.User, messageID, whatsmeow.SendResponse{}, err)
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("failed to upload file: %v", err))
+
 				mu.Unlock()
 				return
 			}
 
 			msg := createImageMessage(uploaded, &data, captionMsg)
-			resp, err := Clients[sender.User].SendMessage(context.Background(), recipient, msg)
+			resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+			ch.logOutboundResult(sender.User, messageID, resp, err)
 			if err != nil {
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("error sending image message: %v", err))
@@ -412,7 +434,7 @@ func NewHandleSendImage(sender types.JID, JIDS []string, data []byte, captionMsg
 				return
 			}
 
-			err = Clients[sender.User].MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+			err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
 			if err != nil {
 				fmt.Errorf("Error sending MarkRead: %v", err)
 				return
@@ -437,7 +459,12 @@ func NewHandleSendImage(sender types.JID, JIDS []string, data []byte, captionMsg
 	return sliceM, nil
 }
 
-func NewHandleSendDocument(sender types.JID, JID []string, fileName string, data []byte, captionMsg string) ([]Message, error) {
+func (ch CommandHandler) NewHandleSendDocument(sender types.JID, JID []string, fileName string, data []byte, captionMsg string) ([]Message, error) {
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var sliceM []Message
@@ -456,22 +483,28 @@ func NewHandleSendDocument(sender types.JID, JID []string, fileName string, data
 				return
 			}
 
-			err := Clients[sender.User].SendPresence(types.PresenceAvailable)
+			err := client.SendPresence(types.PresenceAvailable)
 			if err != nil {
 				fmt.Errorf("Error sending presence: %v", err)
 				return
 			}
 
-			uploaded, err := Clients[sender.User].Upload(context.Background(), data, whatsmeow.MediaDocument)
+			messageID := client.GenerateMessageID()
+			ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: "document", Body: captionMsg, FileName: fileName})
+
+			uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaDocument)
 			if err != nil {
+				ch.logOutboundResult(sender.User, messageID, whatsmeow.SendResponse{}, err)
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("failed to upload file: %v", err))
+
 				mu.Unlock()
 				return
 			}
 
 			msg := createDocumentMessage(fileName, uploaded, &data, captionMsg)
-			resp, err := Clients[sender.User].SendMessage(context.Background(), recipient, msg)
+			resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+			ch.logOutboundResult(sender.User, messageID, resp, err)
 			if err != nil {
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("error sending document message: %v", err))
@@ -479,7 +512,7 @@ func NewHandleSendDocument(sender types.JID, JID []string, fileName string, data
 				return
 			}
 
-			err = Clients[sender.User].MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+			err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
 			if err != nil {
 				fmt.Errorf("Error sending MarkRead: %v", err)
 				return
@@ -504,7 +537,12 @@ func NewHandleSendDocument(sender types.JID, JID []string, fileName string, data
 	return sliceM, nil
 }
 
-func NewHandleSendVideo(sender types.JID, JID []string, data []byte, captionMsg string) ([]Message, error) {
+func (ch CommandHandler) NewHandleSendVideo(sender types.JID, JID []string, data []byte, captionMsg string) ([]Message, error) {
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var sliceM []Message
@@ -523,22 +561,28 @@ func NewHandleSendVideo(sender types.JID, JID []string, data []byte, captionMsg
 				return
 			}
 
-			err := Clients[sender.User].SendPresence(types.PresenceAvailable)
+			err := client.SendPresence(types.PresenceAvailable)
 			if err != nil {
 				fmt.Errorf("Error sending presence: %v", err)
 				return
 			}
 
-			uploaded, err := Clients[sender.User].Upload(context.Background(), data, whatsmeow.MediaImage)
+			messageID := client.GenerateMessageID()
+			ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: "video", Body: captionMsg})
+
+			uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
 			if err != nil {
+				ch.logOutboundResult(sender.User, messageID, whatsmeow.SendResponse{}, err)
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("failed to upload file: %v", err))
+
 				mu.Unlock()
 				return
 			}
 
 			msg := createVideoMessage(uploaded, &data, captionMsg)
-			resp, err := Clients[sender.User].SendMessage(context.Background(), recipient, msg)
+			resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+			ch.logOutboundResult(sender.User, messageID, resp, err)
 			if err != nil {
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("error sending image message: %v", err))
@@ -546,7 +590,7 @@ func NewHandleSendVideo(sender types.JID, JID []string, data []byte, captionMsg
 				return
 			}
 
-			err = Clients[sender.User].MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+			err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
 			if err != nil {
 				fmt.Errorf("Error sending MarkRead: %v", err)
 				return
@@ -571,7 +615,12 @@ func NewHandleSendVideo(sender types.JID, JID []string, data []byte, captionMsg
 	return sliceM, nil
 }
 
-func NewHandleSendAudio(sender types.JID, JID []string, data []byte) ([]Message, error) {
+func (ch CommandHandler) NewHandleSendAudio(sender types.JID, JID []string, data []byte) ([]Message, error) {
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var sliceM []Message
@@ -590,22 +639,28 @@ func NewHandleSendAudio(sender types.JID, JID []string, data []byte) ([]Message,
 				return
 			}
 
-			err := Clients[sender.User].SendPresence(types.PresenceAvailable)
+			err := client.SendPresence(types.PresenceAvailable)
 			if err != nil {
 				fmt.Errorf("Error sending presence: %v", err)
 				return
 			}
 
-			uploaded, err := Clients[sender.User].Upload(context.Background(), data, whatsmeow.MediaImage)
+			messageID := client.GenerateMessageID()
+			ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: "audio"})
+
+			uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
 			if err != nil {
+				ch.logOutboundResult(sender.User, messageID, whatsmeow.SendResponse{}, err)
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("failed to upload file: %v", err))
+
 				mu.Unlock()
 				return
 			}
 
 			msg := createAudioMessage(uploaded, &data)
-			resp, err := Clients[sender.User].SendMessage(context.Background(), recipient, msg)
+			resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+			ch.logOutboundResult(sender.User, messageID, resp, err)
 			if err != nil {
 				mu.Lock()
 				errs = append(errs, fmt.Errorf("error sending image message: %v", err))
@@ -613,7 +668,7 @@ func NewHandleSendAudio(sender types.JID, JID []string, data []byte) ([]Message,
 				return
 			}
 
-			err = Clients[sender.User].MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+			err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
 			if err != nil {
 				fmt.Errorf("Error sending MarkRead: %v", err)
 				return
@@ -661,8 +716,13 @@ func ParseJID(arg string) (types.JID, bool) {
 func (ch CommandHandler) NewHandleCheckUserSingle(sender types.JID, recipient string) (response types.IsOnWhatsAppResponse, err error) {
 	fmt.Printf("Checking users recipient: %v", recipient)
 
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return types.IsOnWhatsAppResponse{}, session.ErrSessionNotFound
+	}
+
 	requestSingleIsOnWhatsapp := []string{recipient}
-	resp, err := Clients[sender.User].IsOnWhatsApp(requestSingleIsOnWhatsapp)
+	resp, err := client.IsOnWhatsApp(requestSingleIsOnWhatsapp)
 	if err != nil {
 		fmt.Errorf("Failed to check if users are on WhatsApp: %v", err)
 		return types.IsOnWhatsAppResponse{}, err
@@ -729,49 +789,43 @@ func generateQRCode(code string) ([]byte, error) {
 	return qrImage, nil
 }
 
+// AutoLogin connects every stored device in the background, a device that fails to connect
+// is retried by its supervisor and does not stop the other devices from loading.
 func (ch CommandHandler) AutoLogin() {
 	devices, err := ch.Container.GetAllDevices()
 	if err != nil {
-		fmt.Errorf("err sqlstore.New : %v ", err)
+		fmt.Printf("err Container.GetAllDevices : %v \n", err)
 		return
 	}
 
 	if len(devices) > 0 {
 		for _, val := range devices {
-			if val.ID.User != "" {
-				device := val
-				//set new client
-				clientLog := waLog.Stdout("Client", "DEBUG", true)
-				client := whatsmeow.NewClient(device, clientLog)
-				client.AddEventHandler(EventHandler)
-
-				// Connect the client synchronously
-				if client.Store.ID != nil {
-					err := client.Connect()
-					if err != nil {
-						fmt.Errorf("err sqlstore.New : %v ", err)
-						return
-					}
-					Clients[val.ID.User] = client
-				}
-			} else {
+			if val.ID == nil || val.ID.User == "" {
 				continue
 			}
+
+			device := val
+			//set new client
+			client := ch.newClient(val.ID.User, device)
+			ch.Sessions.Put(val.ID.User, client)
+
+			sup, ok := ch.supervisors.get(client)
+			if !ok {
+				continue
+			}
+			sup.start(errors.New("auto login"), false)
 		}
 	}
 	return
 }
 
 func (ch CommandHandler) AutoDisconnect() {
-	devices := Clients
+	devices := ch.Sessions.List()
 
 	if len(devices) > 0 {
 		for _, val := range devices {
-			if val.Store.ID.User != "" {
-				// Connect the client synchronously
-				if val.Store.ID != nil {
-					val.Disconnect()
-				}
+			if val.Store.ID != nil && val.Store.ID.User != "" {
+				val.Disconnect()
 			} else {
 				continue
 			}
@@ -781,17 +835,14 @@ func (ch CommandHandler) AutoDisconnect() {
 }
 
 func (ch CommandHandler) AutoLogOut() {
-	devices := Clients
+	devices := ch.Sessions.List()
 
 	if len(devices) > 0 {
 		for _, val := range devices {
-			if val.Store.ID.User != "" {
-				// Connect the client synchronously
-				if val.Store.ID != nil {
-					err := val.Logout()
-					if err != nil {
-						fmt.Errorf("err sqlstore.New : %v ", err)
-					}
+			if val.Store.ID != nil && val.Store.ID.User != "" {
+				err := val.Logout()
+				if err != nil {
+					fmt.Printf("err client.Logout : %v \n", err)
 				}
 			} else {
 				continue
@@ -810,12 +861,21 @@ func (ch CommandHandler) GetAllDevices() (response []primitive.Devices) {
 
 	if len(container) > 0 {
 		for _, item := range container {
+			var isLoggedIn bool
+			if client, ok := ch.Sessions.Get(item.ID.User); ok {
+				isLoggedIn = client.IsLoggedIn()
+			}
+
+			status := ch.SessionStatus(item.ID.User)
 			newItem := primitive.Devices{
-				PushName:   item.PushName,
-				Platform:   item.Platform,
-				User:       item.ID.User,
-				Server:     item.ID.Server,
-				IsLoggedIn: Clients[item.ID.User].IsLoggedIn(),
+				PushName:       item.PushName,
+				Platform:       item.Platform,
+				User:           item.ID.User,
+				Server:         item.ID.Server,
+				IsLoggedIn:     isLoggedIn,
+				State:          string(status.State),
+				LastError:      status.LastError,
+				StateUpdatedAt: status.UpdatedAt,
 			}
 			response = append(response, newItem)
 		}
@@ -841,18 +901,20 @@ func (ch CommandHandler) GetSingleDevices(jid types.JID) (response primitive.Dev
 
 			if jidUser == itemIdUser {
 				var isLoggedIn bool
-				if Clients[item.ID.User] == nil {
-					isLoggedIn = false
-				} else {
-					isLoggedIn = Clients[item.ID.User].IsLoggedIn()
+				if client, ok := ch.Sessions.Get(item.ID.User); ok {
+					isLoggedIn = client.IsLoggedIn()
 				}
 
+				status := ch.SessionStatus(item.ID.User)
 				resp := primitive.Devices{
-					PushName:   item.PushName,
-					Platform:   item.Platform,
-					User:       item.ID.User,
-					Server:     item.ID.Server,
-					IsLoggedIn: isLoggedIn,
+					PushName:       item.PushName,
+					Platform:       item.Platform,
+					User:           item.ID.User,
+					Server:         item.ID.Server,
+					IsLoggedIn:     isLoggedIn,
+					State:          string(status.State),
+					LastError:      status.LastError,
+					StateUpdatedAt: status.UpdatedAt,
 				}
 				return resp
 			}
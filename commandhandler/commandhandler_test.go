@@ -0,0 +1,26 @@
+package commandhandler
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/database"
+)
+
+// newTestDB opens a migrated sqlite database in the temp dir of the test.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	config.Conf.Database = config.Database{
+		Driver:   database.DriverSqlite,
+		DSN:      "file:" + filepath.Join(t.TempDir(), "test.db"),
+		LogLevel: "ERROR",
+	}
+	_, db, err := database.NewDatabase()
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
@@ -0,0 +1,158 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxContacts bounds the contacts of one message, WhatsApp does not show more than that on a contacts array
+const maxContacts = 250
+
+var ErrInvalidContact = errors.New("invalid contact")
+
+// phoneTypes are the TEL types of the vCard a phone may have, the first one is the default
+var phoneTypes = []string{"CELL", "WORK", "HOME", "MAIN"}
+
+// Contact is an outgoing contact card, it is rendered as a vCard 3.0.
+type Contact struct {
+	Name         string
+	Organization string
+	Phones       []ContactPhone
+	Emails       []string
+}
+
+// ContactPhone is a phone number of the contact, Type is one of CELL, WORK, HOME or MAIN.
+type ContactPhone struct {
+	Number string
+	Type   string
+}
+
+// validate checks the contact and normalizes the types of its phones and its emails, the error wraps ErrInvalidContact.
+func (contact *Contact) validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidContact, fmt.Sprintf(format, args...))
+	}
+
+	contact.Name = strings.TrimSpace(contact.Name)
+	if contact.Name == "" {
+		return invalid("name should be filled")
+	}
+	if len(contact.Phones) == 0 && len(contact.Emails) == 0 {
+		return invalid("%s should have at least one phone or email", contact.Name)
+	}
+	for i, phone := range contact.Phones {
+		if digits := phoneDigits(phone.Number); len(digits) < 5 || len(digits) > 15 {
+			return invalid("phone %q of %s is not a valid number", phone.Number, contact.Name)
+		}
+		phone.Type = strings.ToUpper(strings.TrimSpace(phone.Type))
+		if phone.Type == "" {
+			phone.Type = phoneTypes[0]
+		}
+		if !stringInSlice(phone.Type, phoneTypes) {
+			return invalid("phone type %q of %s should be one of %s", phone.Type, contact.Name, strings.Join(phoneTypes, ", "))
+		}
+		contact.Phones[i] = phone
+	}
+	for i, email := range contact.Emails {
+		address, err := mail.ParseAddress(email)
+		if err != nil {
+			return invalid("email %q of %s is not a valid address", email, contact.Name)
+		}
+		contact.Emails[i] = address.Address
+	}
+	return nil
+}
+
+// vCard renders the contact as a vCard 3.0, a phone carries its waid so it can be messaged from the card.
+func (contact Contact) vCard() string {
+	var card strings.Builder
+	line := func(format string, args ...interface{}) {
+		card.WriteString(fmt.Sprintf(format, args...) + "\r\n")
+	}
+
+	line("BEGIN:VCARD")
+	line("VERSION:3.0")
+	line("N:;%s;;;", escapeVCard(contact.Name))
+	line("FN:%s", escapeVCard(contact.Name))
+	if contact.Organization != "" {
+		line("ORG:%s", escapeVCard(contact.Organization))
+	}
+	for _, phone := range contact.Phones {
+		line("TEL;type=%s;waid=%s:%s", phone.Type, phoneDigits(phone.Number), escapeVCard(strings.TrimSpace(phone.Number)))
+	}
+	for _, email := range contact.Emails {
+		line("EMAIL;type=INTERNET:%s", escapeVCard(email))
+	}
+	line("END:VCARD")
+	return card.String()
+}
+
+// escapeVCard escapes a text value of the vCard.
+func escapeVCard(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// phoneDigits returns the digits of the number, it is the number without the plus sign, spaces and separators.
+func phoneDigits(number string) string {
+	var digits strings.Builder
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return digits.String()
+}
+
+func stringInSlice(value string, slice []string) bool {
+	for _, v := range slice {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// createContactMessage builds a contact message for one contact and a contacts array for more.
+func createContactMessage(contacts []Contact) *waProto.Message {
+	cards := make([]*waProto.ContactMessage, 0, len(contacts))
+	for _, contact := range contacts {
+		cards = append(cards, &waProto.ContactMessage{
+			DisplayName: proto.String(contact.Name),
+			Vcard:       proto.String(contact.vCard()),
+		})
+	}
+	if len(cards) == 1 {
+		return &waProto.Message{ContactMessage: cards[0]}
+	}
+	return &waProto.Message{ContactsArrayMessage: &waProto.ContactsArrayMessage{
+		DisplayName: proto.String(fmt.Sprintf("%d contacts", len(cards))),
+		Contacts:    cards,
+	}}
+}
+
+// NewHandleSendContacts sends the contact cards to every recipient, one contact is sent as a contact message
+// and more as a contacts array.
+func (ch CommandHandler) NewHandleSendContacts(sender types.JID, JIDS []string, contacts []Contact) ([]Message, error) {
+	if len(contacts) == 0 || len(contacts) > maxContacts {
+		return nil, fmt.Errorf("%w: contacts should have 1 to %d contacts", ErrInvalidContact, maxContacts)
+	}
+	names := make([]string, 0, len(contacts))
+	for i := range contacts {
+		if err := contacts[i].validate(); err != nil {
+			return nil, err
+		}
+		names = append(names, contacts[i].Name)
+	}
+
+	msgType := MessageTypeContact
+	if len(contacts) > 1 {
+		msgType = MessageTypeContacts
+	}
+	return ch.sendToRecipients(sender, JIDS, msgType, strings.Join(names, ", "), createContactMessage(contacts))
+}
@@ -0,0 +1,75 @@
+package commandhandler
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestContactVCard(t *testing.T) {
+	contact := Contact{
+		Name:         "Budi; Santoso, Jr.",
+		Organization: "Toko Maju",
+		Phones:       []ContactPhone{{Number: "+62 812-3456-7890", Type: "cell"}, {Number: "021 555 0101"}},
+		Emails:       []string{"Budi <budi@example.com>"},
+	}
+	if err := contact.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	want := "BEGIN:VCARD\r\n" +
+		"VERSION:3.0\r\n" +
+		"N:;Budi\\; Santoso\\, Jr.;;;\r\n" +
+		"FN:Budi\\; Santoso\\, Jr.\r\n" +
+		"ORG:Toko Maju\r\n" +
+		"TEL;type=CELL;waid=6281234567890:+62 812-3456-7890\r\n" +
+		"TEL;type=CELL;waid=0215550101:021 555 0101\r\n" +
+		"EMAIL;type=INTERNET:budi@example.com\r\n" +
+		"END:VCARD\r\n"
+	if got := contact.vCard(); got != want {
+		t.Fatalf("vCard =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestContactValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		contact Contact
+		wantErr bool
+	}{
+		{name: "phone", contact: Contact{Name: "Budi", Phones: []ContactPhone{{Number: "+62 812 3456 7890"}}}},
+		{name: "email", contact: Contact{Name: "Budi", Emails: []string{"budi@example.com"}}},
+		{name: "phone type", contact: Contact{Name: "Budi", Phones: []ContactPhone{{Number: "6281234567", Type: " work "}}}},
+		{name: "no name", contact: Contact{Name: " ", Emails: []string{"budi@example.com"}}, wantErr: true},
+		{name: "no phone or email", contact: Contact{Name: "Budi"}, wantErr: true},
+		{name: "short phone", contact: Contact{Name: "Budi", Phones: []ContactPhone{{Number: "1234"}}}, wantErr: true},
+		{name: "long phone", contact: Contact{Name: "Budi", Phones: []ContactPhone{{Number: "1234567890123456"}}}, wantErr: true},
+		{name: "unknown phone type", contact: Contact{Name: "Budi", Phones: []ContactPhone{{Number: "6281234567", Type: "FAX"}}}, wantErr: true},
+		{name: "invalid email", contact: Contact{Name: "Budi", Emails: []string{"budi"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.contact.validate()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validate = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidContact) {
+				t.Fatalf("err = %v, want ErrInvalidContact", err)
+			}
+		})
+	}
+}
+
+func TestCreateContactMessage(t *testing.T) {
+	one := createContactMessage([]Contact{{Name: "Budi", Emails: []string{"budi@example.com"}}})
+	if one.GetContactMessage().GetDisplayName() != "Budi" || one.ContactsArrayMessage != nil {
+		t.Fatalf("one contact = %v, want a contact message", one)
+	}
+
+	many := createContactMessage([]Contact{{Name: "Budi"}, {Name: "Sari"}})
+	if many.ContactMessage != nil || len(many.GetContactsArrayMessage().GetContacts()) != 2 {
+		t.Fatalf("two contacts = %v, want a contacts array of 2", many)
+	}
+	if got := many.GetContactsArrayMessage().GetDisplayName(); got != "2 contacts" {
+		t.Fatalf("display name = %q, want %q", got, "2 contacts")
+	}
+}
@@ -0,0 +1,85 @@
+package commandhandler
+
+import (
+	"fmt"
+	"strings"
+
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// DeleteSessionResult reports every step that is done to remove a session.
+type DeleteSessionResult struct {
+	User                string `json:"user"`
+	Disconnected        bool   `json:"disconnected"`
+	LoggedOut           bool   `json:"loggedOut"`
+	LogoutError         string `json:"logoutError,omitempty"`
+	DeletedFromStore    bool   `json:"deletedFromStore"`
+	RemovedFromRegistry bool   `json:"removedFromRegistry"`
+}
+
+// storedDevice returns the device of the user that is saved on the sqlstore, nil when there is none.
+func (ch CommandHandler) storedDevice(user string) (*store.Device, error) {
+	devices, err := ch.Container.GetAllDevices()
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if device.ID != nil && strings.TrimSpace(device.ID.User) == strings.TrimSpace(user) {
+			return device, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteSession disconnects the session of the jid, logs it out when it is still logged in,
+// deletes the device from the sqlstore and removes the session from the registry.
+// the device is deleted even when the logout fails, e.g. because the credentials are already invalid.
+func (ch CommandHandler) DeleteSession(jid types.JID) (result DeleteSessionResult, err error) {
+	result.User = jid.User
+
+	client, hasClient := ch.Sessions.Get(jid.User)
+	device, err := ch.storedDevice(jid.User)
+	if err != nil {
+		return result, err
+	}
+	if !hasClient && device == nil {
+		return result, session.ErrSessionNotFound
+	}
+
+	if hasClient {
+		if client.IsLoggedIn() {
+			errLogout := client.Logout()
+			if errLogout != nil {
+				fmt.Printf("err client.Logout on delete session %s : %v \n", jid.User, errLogout)
+				result.LogoutError = errLogout.Error()
+			} else {
+				// logout already deletes the device from the store
+				result.LoggedOut = true
+				result.DeletedFromStore = true
+			}
+		}
+		client.Disconnect()
+		result.Disconnected = true
+
+		ch.pairings.remove(jid.User, client)
+		result.RemovedFromRegistry = ch.Sessions.CompareAndDelete(jid.User, client)
+	}
+
+	if !result.DeletedFromStore && device != nil {
+		err = device.Delete()
+		if err != nil {
+			return result, err
+		}
+		if hasClient && client.Store.ID != nil && client.Store.ID.User == jid.User {
+			// keep the client in sync with the deleted store
+			client.Store.ID = nil
+		}
+		result.DeletedFromStore = true
+	}
+
+	ch.Sessions.SetState(jid.User, session.StateLoggedOut, nil)
+	return result, nil
+}
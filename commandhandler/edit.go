@@ -0,0 +1,146 @@
+package commandhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// RevokeWindow is how long a message can be deleted for everyone after it was sent,
+// the edit window is whatsmeow.EditWindow.
+const RevokeWindow = 60 * time.Hour
+
+var (
+	ErrInvalidEdit   = errors.New("invalid edit")
+	ErrInvalidRevoke = errors.New("invalid revoke")
+)
+
+// sentMessage returns a message the sender sent to the chat from the message log, it checks that the message was
+// sent within the window of the action and was not revoked. The error wraps invalid, or is repository.ErrNotFound.
+func (ch CommandHandler) sentMessage(sender, chat types.JID, messageID, action string, window time.Duration, invalid error) (repository.OutboundMessage, error) {
+	if ch.Messages == nil {
+		return repository.OutboundMessage{}, fmt.Errorf("%w: the message log is not enabled", invalid)
+	}
+	msg, err := ch.Messages.GetOutbound(sender.User, messageID)
+	if err != nil {
+		return msg, err
+	}
+	if msg.Recipient != chat.ToNonAD().String() {
+		return msg, repository.ErrNotFound
+	}
+
+	switch {
+	case msg.Status == repository.StatusPending || msg.Status == repository.StatusFailed || msg.SentAt == nil:
+		return msg, fmt.Errorf("%w: the message was not sent", invalid)
+	case msg.RevokedAt != nil:
+		return msg, fmt.Errorf("%w: the message is already revoked", invalid)
+	case time.Since(*msg.SentAt) > window:
+		return msg, fmt.Errorf("%w: the message can only be %s within %s after it was sent, it was sent at %s",
+			invalid, action, window, msg.SentAt.Format(time.RFC3339))
+	}
+	return msg, nil
+}
+
+// HandleEditMessage replaces the text of a text message the sender sent to the chat, it is only possible
+// within whatsmeow.EditWindow after the message was sent. The message must be on the message log.
+func (ch CommandHandler) HandleEditMessage(sender types.JID, chat, messageID, textMsg string) (string, error) {
+	chatJID, ok := ParseJID(chat)
+	if !ok {
+		return "", fmt.Errorf("%w: chat %q is not a valid jid", ErrInvalidEdit, chat)
+	}
+	if strings.TrimSpace(textMsg) == "" {
+		return "", fmt.Errorf("%w: message should be filled", ErrInvalidEdit)
+	}
+
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return "", session.ErrSessionNotFound
+	}
+
+	original, err := ch.sentMessage(sender, chatJID, messageID, "edited", whatsmeow.EditWindow, ErrInvalidEdit)
+	if err != nil {
+		return "", err
+	}
+	if original.Type != MessageTypeText {
+		return "", fmt.Errorf("%w: only a text message can be edited, the message is %s", ErrInvalidEdit, original.Type)
+	}
+
+	msg := client.BuildEdit(chatJID, messageID, &waProto.Message{Conversation: proto.String(textMsg)})
+	resp, err := client.SendMessage(context.Background(), chatJID, msg)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = ch.Messages.MarkOutboundEdited(sender.User, messageID, textMsg, resp.Timestamp); err != nil {
+		fmt.Printf("err Messages.MarkOutboundEdited %s : %v \n", messageID, err)
+	}
+	return resp.ID, nil
+}
+
+// HandleRevokeMessage deletes a message of the chat for everyone, it is only possible within RevokeWindow after
+// the message was sent. A message of another participant of a group is revoked as an admin of the group,
+// it must be on the message log like the messages of the sender.
+func (ch CommandHandler) HandleRevokeMessage(sender types.JID, chat, messageID string) (string, error) {
+	chatJID, ok := ParseJID(chat)
+	if !ok {
+		return "", fmt.Errorf("%w: chat %q is not a valid jid", ErrInvalidRevoke, chat)
+	}
+
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return "", session.ErrSessionNotFound
+	}
+
+	target := types.EmptyJID
+	original, err := ch.sentMessage(sender, chatJID, messageID, "revoked", RevokeWindow, ErrInvalidRevoke)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		// a message of another participant, only a group admin can revoke it
+		received, err := ch.Messages.GetChatMessage(sender.User, chatJID.ToNonAD().String(), messageID)
+		if err != nil {
+			return "", err
+		}
+		if received.Direction != repository.DirectionInbound {
+			return "", repository.ErrNotFound
+		}
+		if chatJID.Server != types.GroupServer {
+			return "", fmt.Errorf("%w: a message of the contact can not be revoked on a private chat", ErrInvalidRevoke)
+		}
+		if time.Since(received.Timestamp) > RevokeWindow {
+			return "", fmt.Errorf("%w: the message can only be revoked within %s after it was sent, it was sent at %s",
+				ErrInvalidRevoke, RevokeWindow, received.Timestamp.Format(time.RFC3339))
+		}
+		if received.Type == MessageTypeReaction {
+			return "", fmt.Errorf("%w: a reaction can not be revoked", ErrInvalidRevoke)
+		}
+		if target, ok = ParseJID(received.Sender); !ok {
+			return "", fmt.Errorf("%w: sender %q of the message is not a valid jid", ErrInvalidRevoke, received.Sender)
+		}
+	case err != nil:
+		return "", err
+	case original.Type == MessageTypeReaction:
+		return "", fmt.Errorf("%w: a reaction is removed by sending an empty reaction", ErrInvalidRevoke)
+	}
+
+	resp, err := client.SendMessage(context.Background(), chatJID, client.BuildRevoke(chatJID, target, messageID))
+	if err != nil {
+		return "", err
+	}
+
+	if target.IsEmpty() {
+		if _, err = ch.Messages.MarkOutboundRevoked(sender.User, messageID, resp.Timestamp); err != nil {
+			fmt.Printf("err Messages.MarkOutboundRevoked %s : %v \n", messageID, err)
+		}
+	}
+	return resp.ID, nil
+}
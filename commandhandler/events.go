@@ -0,0 +1,135 @@
+package commandhandler
+
+import (
+	"fmt"
+	"time"
+
+	"whatsapp_multi_session_general/primitive"
+
+	"github.com/gookit/event"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Publish fires the serialized event of the session on primitive.WhatsappEvent,
+// the webhooks and the event stream listen on it.
+func (ch CommandHandler) Publish(user, eventType string, data interface{}) {
+	payload := primitive.Event{
+		ID:        primitive.GenerateEventID(),
+		Event:     eventType,
+		Session:   user,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	err, _ := event.Fire(primitive.WhatsappEvent, event.M{primitive.WhatsappEventKey: payload})
+	if err != nil {
+		fmt.Printf("err event.Fire %s of %s : %v \n", eventType, user, err)
+	}
+}
+
+// publishEventHandler serializes the whatsmeow events of the session and publishes them.
+func (ch CommandHandler) publishEventHandler(requestedUser string, client *whatsmeow.Client) whatsmeow.EventHandler {
+	return func(evt interface{}) {
+		user := ch.sessionKey(requestedUser, client)
+		switch v := evt.(type) {
+		case *events.Message:
+			// a reaction is not shown as a message of the chat, it is published as its own event
+			if data, ok := reactionEventData(user, v); ok {
+				ch.Publish(user, primitive.EventReaction, data)
+			} else if data, ok := ch.messageEventData(user, v); ok {
+				ch.Publish(user, primitive.EventMessage, data)
+			}
+		case *events.Receipt:
+			ch.Publish(user, primitive.EventReceipt, receiptEventData(v))
+		case *events.Presence:
+			data := primitive.PresenceEvent{From: v.From.ToNonAD().String(), Unavailable: v.Unavailable}
+			if !v.LastSeen.IsZero() {
+				data.LastSeen = &v.LastSeen
+			}
+			ch.Publish(user, primitive.EventPresence, data)
+		case *events.ChatPresence:
+			ch.Publish(user, primitive.EventChatPresence, primitive.ChatPresenceEvent{
+				Chat:    v.Chat.ToNonAD().String(),
+				Sender:  v.Sender.ToNonAD().String(),
+				IsGroup: v.IsGroup,
+				State:   string(v.State),
+				Media:   string(v.Media),
+			})
+		case *events.Connected:
+			data := primitive.ConnectionEvent{}
+			if client.Store.ID != nil {
+				data.JID = client.Store.ID.ToNonAD().String()
+			}
+			ch.Publish(user, primitive.EventConnected, data)
+		case *events.Disconnected:
+			ch.Publish(user, primitive.EventDisconnected, primitive.ConnectionEvent{})
+		case *events.PairSuccess:
+			ch.Publish(user, primitive.EventPairSuccess, primitive.ConnectionEvent{JID: v.ID.ToNonAD().String(), Message: v.Platform})
+		case *events.LoggedOut:
+			ch.Publish(user, primitive.EventLoggedOut, primitive.ConnectionEvent{Reason: v.Reason.String(), Message: v.PermanentDisconnectDescription()})
+		case *events.StreamReplaced:
+			ch.Publish(user, primitive.EventStreamReplaced, primitive.ConnectionEvent{Message: v.PermanentDisconnectDescription()})
+		case *events.ConnectFailure:
+			ch.Publish(user, primitive.EventConnectFailure, primitive.ConnectionEvent{Reason: v.Reason.String(), Message: v.Message, Code: int(v.Reason)})
+		case *events.TemporaryBan:
+			data := primitive.ConnectionEvent{Reason: v.Code.String(), Message: v.String(), Code: int(v.Code)}
+			if v.Expire > 0 {
+				expiresAt := time.Now().Add(v.Expire)
+				data.ExpiresAt = &expiresAt
+			}
+			ch.Publish(user, primitive.EventTemporaryBan, data)
+		}
+	}
+}
+
+// messageEventData returns the data of a message event, ok is false for messages that are not shown on the chat.
+func (ch CommandHandler) messageEventData(user string, evt *events.Message) (data primitive.MessageEvent, ok bool) {
+	if evt.Info.Chat == types.StatusBroadcastJID {
+		return data, false
+	}
+	content := parseMessageContent(evt.Message)
+	if content.Type == "" {
+		return data, false
+	}
+
+	// the media id is derived from the message, the download itself is started by the message log
+	mediaID := ch.inboundMediaID(user, evt, content)
+
+	return primitive.MessageEvent{
+		MessageID:       evt.Info.ID,
+		Chat:            evt.Info.Chat.ToNonAD().String(),
+		Sender:          evt.Info.Sender.ToNonAD().String(),
+		PushName:        evt.Info.PushName,
+		IsGroup:         evt.Info.IsGroup,
+		IsFromMe:        evt.Info.IsFromMe,
+		Type:            content.Type,
+		Text:            content.Text,
+		MimeType:        content.MimeType,
+		FileName:        content.FileName,
+		MediaID:         mediaID,
+		MediaURL:        mediaURL(mediaID),
+		QuotedMessageID: content.QuotedMessageID,
+		QuotedSender:    content.QuotedSender,
+		Timestamp:       evt.Info.Timestamp,
+	}, true
+}
+
+func receiptEventData(evt *events.Receipt) primitive.ReceiptEvent {
+	receiptType := string(evt.Type)
+	if evt.Type == types.ReceiptTypeDelivered {
+		receiptType = "delivered"
+	}
+	data := primitive.ReceiptEvent{
+		MessageIDs: evt.MessageIDs,
+		Chat:       evt.Chat.ToNonAD().String(),
+		Sender:     evt.Sender.ToNonAD().String(),
+		IsGroup:    evt.IsGroup,
+		Type:       receiptType,
+		Timestamp:  evt.Timestamp,
+	}
+	if !evt.IsFromMe {
+		data.Status = receiptStatus[evt.Type]
+	}
+	return data
+}
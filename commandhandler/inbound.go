@@ -0,0 +1,215 @@
+package commandhandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	MessageTypeText         = "text"
+	MessageTypeImage        = "image"
+	MessageTypeVideo        = "video"
+	MessageTypeAudio        = "audio"
+	MessageTypeVoice        = "voice"
+	MessageTypeDocument     = "document"
+	MessageTypeSticker      = "sticker"
+	MessageTypeLocation     = "location"
+	MessageTypeLiveLocation = "live_location"
+	MessageTypeContact      = "contact"
+	MessageTypeContacts     = "contacts"
+	MessageTypeReaction     = "reaction"
+	MessageTypePoll         = "poll"
+	MessageTypeUnknown      = "unknown"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// messageContent is the part of a whatsapp message that is kept on the message log.
+type messageContent struct {
+	Type            string
+	Text            string
+	MimeType        string
+	FileName        string
+	QuotedMessageID string
+	QuotedSender    string
+	// Media is the downloadable part of a media message, FileLength is its size in bytes
+	Media      whatsmeow.DownloadableMessage
+	FileLength uint64
+}
+
+type contextInfoMessage interface {
+	GetContextInfo() *waProto.ContextInfo
+}
+
+// parseMessageContent returns the type and the text or caption of the message,
+// the type is empty for messages that are not shown on the chat (protocol and key distribution messages).
+func parseMessageContent(msg *waProto.Message) (content messageContent) {
+	if msg == nil {
+		return content
+	}
+
+	var withContext contextInfoMessage
+	switch {
+	case msg.Conversation != nil:
+		content.Type, content.Text = MessageTypeText, msg.GetConversation()
+	case msg.ExtendedTextMessage != nil:
+		content.Type, content.Text = MessageTypeText, msg.GetExtendedTextMessage().GetText()
+		withContext = msg.GetExtendedTextMessage()
+	case msg.ImageMessage != nil:
+		v := msg.GetImageMessage()
+		content.Type, content.Text, content.MimeType = MessageTypeImage, v.GetCaption(), v.GetMimetype()
+		content.Media, content.FileLength = v, v.GetFileLength()
+		withContext = v
+	case msg.VideoMessage != nil:
+		v := msg.GetVideoMessage()
+		content.Type, content.Text, content.MimeType = MessageTypeVideo, v.GetCaption(), v.GetMimetype()
+		content.Media, content.FileLength = v, v.GetFileLength()
+		withContext = v
+	case msg.PtvMessage != nil:
+		v := msg.GetPtvMessage()
+		content.Type, content.Text, content.MimeType = MessageTypeVideo, v.GetCaption(), v.GetMimetype()
+		content.Media, content.FileLength = v, v.GetFileLength()
+		withContext = v
+	case msg.AudioMessage != nil:
+		v := msg.GetAudioMessage()
+		content.Type, content.MimeType = MessageTypeAudio, v.GetMimetype()
+		if v.GetPtt() {
+			content.Type = MessageTypeVoice
+		}
+		content.Media, content.FileLength = v, v.GetFileLength()
+		withContext = v
+	case msg.DocumentMessage != nil:
+		v := msg.GetDocumentMessage()
+		content.Type, content.Text, content.MimeType, content.FileName = MessageTypeDocument, v.GetCaption(), v.GetMimetype(), v.GetFileName()
+		content.Media, content.FileLength = v, v.GetFileLength()
+		withContext = v
+	case msg.StickerMessage != nil:
+		v := msg.GetStickerMessage()
+		content.Type, content.MimeType = MessageTypeSticker, v.GetMimetype()
+		content.Media, content.FileLength = v, v.GetFileLength()
+		withContext = v
+	case msg.LocationMessage != nil:
+		v := msg.GetLocationMessage()
+		content.Type = MessageTypeLocation
+		content.Text = strings.TrimSpace(fmt.Sprintf("%s %s", v.GetName(), v.GetAddress()))
+		if content.Text == "" {
+			content.Text = fmt.Sprintf("%f,%f", v.GetDegreesLatitude(), v.GetDegreesLongitude())
+		}
+		withContext = v
+	case msg.LiveLocationMessage != nil:
+		v := msg.GetLiveLocationMessage()
+		content.Type, content.Text = MessageTypeLiveLocation, v.GetCaption()
+		withContext = v
+	case msg.ContactMessage != nil:
+		v := msg.GetContactMessage()
+		content.Type, content.Text = MessageTypeContact, v.GetDisplayName()
+		withContext = v
+	case msg.ContactsArrayMessage != nil:
+		v := msg.GetContactsArrayMessage()
+		content.Type, content.Text = MessageTypeContacts, v.GetDisplayName()
+		withContext = v
+	case msg.ReactionMessage != nil:
+		v := msg.GetReactionMessage()
+		content.Type, content.Text = MessageTypeReaction, v.GetText()
+		content.QuotedMessageID = v.GetKey().GetId()
+		content.QuotedSender = v.GetKey().GetParticipant()
+	case msg.PollCreationMessage != nil, msg.PollCreationMessageV2 != nil, msg.PollCreationMessageV3 != nil:
+		content.Type = MessageTypePoll
+		for _, poll := range []*waProto.PollCreationMessage{msg.PollCreationMessage, msg.PollCreationMessageV2, msg.PollCreationMessageV3} {
+			if poll != nil {
+				content.Text = poll.GetName()
+				withContext = poll
+				break
+			}
+		}
+	case msg.ProtocolMessage != nil, msg.SenderKeyDistributionMessage != nil:
+		return content
+	default:
+		content.Type = MessageTypeUnknown
+	}
+
+	if withContext != nil {
+		if contextInfo := withContext.GetContextInfo(); contextInfo.GetStanzaId() != "" {
+			content.QuotedMessageID = contextInfo.GetStanzaId()
+			content.QuotedSender = contextInfo.GetParticipant()
+		}
+	}
+	return content
+}
+
+// logInbound stores a message received by the session and starts the download of its media,
+// messages sent from our own devices and status updates are skipped.
+func (ch CommandHandler) logInbound(user string, client *whatsmeow.Client, evt *events.Message) {
+	if ch.Messages == nil || evt.Info.IsFromMe || evt.Info.Chat == types.StatusBroadcastJID {
+		return
+	}
+	content := parseMessageContent(evt.Message)
+	if content.Type == "" {
+		return
+	}
+
+	mediaID := ch.inboundMediaID(user, evt, content)
+	if mediaID != "" {
+		ch.downloadMedia(user, client, evt, content, mediaID)
+	}
+
+	err := ch.Messages.CreateInbound(repository.InboundMessage{
+		Session:         user,
+		Chat:            evt.Info.Chat.ToNonAD().String(),
+		MessageID:       evt.Info.ID,
+		Sender:          evt.Info.Sender.ToNonAD().String(),
+		PushName:        evt.Info.PushName,
+		IsGroup:         evt.Info.IsGroup,
+		Type:            content.Type,
+		Text:            content.Text,
+		MimeType:        content.MimeType,
+		FileName:        content.FileName,
+		MediaID:         mediaID,
+		QuotedMessageID: content.QuotedMessageID,
+		QuotedSender:    content.QuotedSender,
+		Timestamp:       evt.Info.Timestamp,
+	})
+	if err != nil {
+		fmt.Printf("err Messages.CreateInbound %s : %v \n", evt.Info.ID, err)
+	}
+}
+
+// ListChats returns a page of the chats of the sender ordered by the last activity.
+func (ch CommandHandler) ListChats(sender types.JID, limit, offset int) ([]repository.Chat, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	return ch.Messages.ListChats(sender.User, historyLimit(limit), offset)
+}
+
+// ChatTimeline returns a page of the messages of a chat after the cursor of the previous page, newest first.
+// a zero before starts from the newest message, beforeID is ignored without before.
+func (ch CommandHandler) ChatTimeline(sender types.JID, chat types.JID, before time.Time, beforeID string, limit int) ([]repository.TimelineEntry, error) {
+	if before.IsZero() {
+		// the end of the range is exclusive, messages of this millisecond are included
+		before = time.Now().Add(time.Millisecond)
+		beforeID = ""
+	}
+	return ch.Messages.ListTimeline(sender.User, chat.ToNonAD().String(), before, beforeID, historyLimit(limit))
+}
+
+func historyLimit(limit int) int {
+	if limit <= 0 {
+		return defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		return maxHistoryLimit
+	}
+	return limit
+}
@@ -0,0 +1,135 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxThumbnailSize bounds the inline thumbnail, WhatsApp shows a small preview and drops large ones.
+const maxThumbnailSize = 100 << 10
+
+var ErrInvalidLocation = errors.New("invalid location")
+
+// Location is an outgoing location, a venue when it has a name or an address, a live location when Live is set.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	Name      string
+	Address   string
+	URL       string
+	// Thumbnail is the JPEG preview of the map
+	Thumbnail []byte
+	// Live shares a live location, Caption is shown with it and SequenceNumber orders its updates
+	Live           bool
+	Caption        string
+	SequenceNumber int64
+	// AccuracyInMeters is the radius of the position, zero when unknown
+	AccuracyInMeters uint32
+}
+
+// validate checks the coordinates and the optional parts of the location, the error wraps ErrInvalidLocation.
+func (loc Location) validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidLocation, fmt.Sprintf(format, args...))
+	}
+
+	if math.IsNaN(loc.Latitude) || loc.Latitude < -90 || loc.Latitude > 90 {
+		return invalid("latitude should be between -90 and 90")
+	}
+	if math.IsNaN(loc.Longitude) || loc.Longitude < -180 || loc.Longitude > 180 {
+		return invalid("longitude should be between -180 and 180")
+	}
+	if loc.URL != "" {
+		parsed, err := url.Parse(loc.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return invalid("url should be an http or https url")
+		}
+	}
+	if len(loc.Thumbnail) > 0 {
+		if len(loc.Thumbnail) > maxThumbnailSize {
+			return invalid("thumbnail should be at most %d KB", maxThumbnailSize>>10)
+		}
+		if http.DetectContentType(loc.Thumbnail) != "image/jpeg" {
+			return invalid("thumbnail should be a JPEG image")
+		}
+	}
+	if loc.Live && (loc.Name != "" || loc.Address != "" || loc.URL != "") {
+		return invalid("a live location has no name, address or url, use caption")
+	}
+	if !loc.Live && (loc.Caption != "" || loc.SequenceNumber != 0) {
+		return invalid("caption and sequenceNumber are only for a live location")
+	}
+	if loc.SequenceNumber < 0 {
+		return invalid("sequenceNumber should not be negative")
+	}
+	return nil
+}
+
+// body is how the location is recorded on the message log.
+func (loc Location) body() string {
+	if loc.Live {
+		if loc.Caption != "" {
+			return loc.Caption
+		}
+	} else if text := strings.TrimSpace(fmt.Sprintf("%s %s", loc.Name, loc.Address)); text != "" {
+		return text
+	}
+	return fmt.Sprintf("%f,%f", loc.Latitude, loc.Longitude)
+}
+
+func createLocationMessage(loc Location) *waProto.Message {
+	if loc.Live {
+		live := &waProto.LiveLocationMessage{
+			DegreesLatitude:  proto.Float64(loc.Latitude),
+			DegreesLongitude: proto.Float64(loc.Longitude),
+			SequenceNumber:   proto.Int64(loc.SequenceNumber),
+			JpegThumbnail:    loc.Thumbnail,
+		}
+		if loc.Caption != "" {
+			live.Caption = proto.String(loc.Caption)
+		}
+		if loc.AccuracyInMeters > 0 {
+			live.AccuracyInMeters = proto.Uint32(loc.AccuracyInMeters)
+		}
+		return &waProto.Message{LiveLocationMessage: live}
+	}
+
+	location := &waProto.LocationMessage{
+		DegreesLatitude:  proto.Float64(loc.Latitude),
+		DegreesLongitude: proto.Float64(loc.Longitude),
+		JpegThumbnail:    loc.Thumbnail,
+	}
+	if loc.Name != "" {
+		location.Name = proto.String(loc.Name)
+	}
+	if loc.Address != "" {
+		location.Address = proto.String(loc.Address)
+	}
+	if loc.URL != "" {
+		location.Url = proto.String(loc.URL)
+	}
+	if loc.AccuracyInMeters > 0 {
+		location.AccuracyInMeters = proto.Uint32(loc.AccuracyInMeters)
+	}
+	return &waProto.Message{LocationMessage: location}
+}
+
+// NewHandleSendLocation sends the location, venue or live location to every recipient.
+func (ch CommandHandler) NewHandleSendLocation(sender types.JID, JIDS []string, loc Location) ([]Message, error) {
+	if err := loc.validate(); err != nil {
+		return nil, err
+	}
+	msgType := MessageTypeLocation
+	if loc.Live {
+		msgType = MessageTypeLiveLocation
+	}
+	return ch.sendToRecipients(sender, JIDS, msgType, loc.body(), createLocationMessage(loc))
+}
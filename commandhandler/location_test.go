@@ -0,0 +1,64 @@
+package commandhandler
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLocationValidate(t *testing.T) {
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}
+
+	tests := []struct {
+		name    string
+		loc     Location
+		wantErr bool
+	}{
+		{name: "location", loc: Location{Latitude: -6.2, Longitude: 106.8}},
+		{name: "venue", loc: Location{Latitude: -6.2, Longitude: 106.8, Name: "Monas", Address: "Jakarta", URL: "https://example.com"}},
+		{name: "live", loc: Location{Latitude: -6.2, Longitude: 106.8, Live: true, Caption: "on my way", SequenceNumber: 2}},
+		{name: "thumbnail", loc: Location{Latitude: -6.2, Longitude: 106.8, Thumbnail: jpeg}},
+		{name: "latitude", loc: Location{Latitude: 90.1}, wantErr: true},
+		{name: "latitude nan", loc: Location{Latitude: math.NaN()}, wantErr: true},
+		{name: "longitude", loc: Location{Longitude: -180.1}, wantErr: true},
+		{name: "url scheme", loc: Location{Name: "Monas", URL: "javascript:alert(1)"}, wantErr: true},
+		{name: "thumbnail not jpeg", loc: Location{Thumbnail: []byte("\x89PNG\r\n\x1a\n")}, wantErr: true},
+		{name: "thumbnail too large", loc: Location{Thumbnail: append(jpeg, bytes.Repeat([]byte{0}, maxThumbnailSize)...)}, wantErr: true},
+		{name: "live with name", loc: Location{Live: true, Name: "Monas"}, wantErr: true},
+		{name: "caption without live", loc: Location{Caption: "on my way"}, wantErr: true},
+		{name: "negative sequence", loc: Location{Live: true, SequenceNumber: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.loc.validate()
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validate = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidLocation) {
+				t.Fatalf("err = %v, want ErrInvalidLocation", err)
+			}
+		})
+	}
+}
+
+func TestLocationBody(t *testing.T) {
+	tests := []struct {
+		name string
+		loc  Location
+		want string
+	}{
+		{name: "coordinates", loc: Location{Latitude: -6.175392, Longitude: 106.827153}, want: "-6.175392,106.827153"},
+		{name: "venue", loc: Location{Name: "Monas", Address: "Jakarta Pusat"}, want: "Monas Jakarta Pusat"},
+		{name: "address", loc: Location{Address: "Jakarta Pusat"}, want: "Jakarta Pusat"},
+		{name: "live caption", loc: Location{Live: true, Caption: "on my way"}, want: "on my way"},
+		{name: "live", loc: Location{Live: true, Latitude: 1, Longitude: 2}, want: "1.000000,2.000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.loc.body(); got != tt.want {
+				t.Fatalf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package commandhandler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/media"
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultMediaWorkers = 4
+	defaultMediaTimeout = 2 * time.Minute
+	// maxMediaAttempts bounds the downloads of a media, a failed media is downloaded again when it is requested
+	maxMediaAttempts = 3
+)
+
+var (
+	ErrMediaPending = errors.New("media is still downloading")
+	ErrMediaFailed  = errors.New("media download failed")
+)
+
+// newMediaSlots limits the number of media that are downloaded at the same time.
+func newMediaSlots() chan struct{} {
+	workers := config.Conf.Media.Workers
+	if workers <= 0 {
+		workers = defaultMediaWorkers
+	}
+	return make(chan struct{}, workers)
+}
+
+// mediaID is derived from the message, so a redelivered message refers to the same media.
+func mediaID(user, chat, messageID string) string {
+	sum := sha256.Sum256([]byte(user + "|" + chat + "|" + messageID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// mediaURL is the url of the media on GET /media/:id.
+func mediaURL(id string) string {
+	if id == "" {
+		return ""
+	}
+	return strings.TrimRight(config.Conf.Media.PublicURL, "/") + "/media/" + id
+}
+
+// inboundMediaID returns the id of the media of a received message, it is empty when the media is not downloaded.
+func (ch CommandHandler) inboundMediaID(user string, evt *events.Message, content messageContent) string {
+	conf := config.Conf.Media
+	if !conf.Download || ch.MediaStore == nil || content.Media == nil || evt.Info.IsFromMe {
+		return ""
+	}
+	if conf.MaxSize > 0 && content.FileLength > uint64(conf.MaxSize) {
+		return ""
+	}
+	return mediaID(user, evt.Info.Chat.ToNonAD().String(), evt.Info.ID)
+}
+
+// downloadMedia records the media as pending and downloads it in the background,
+// the whatsmeow event handlers are not blocked by a large media.
+// The message is kept with the media so a failed download can be retried.
+func (ch CommandHandler) downloadMedia(user string, client *whatsmeow.Client, evt *events.Message, content messageContent, id string) {
+	message, err := proto.Marshal(evt.Message)
+	if err != nil {
+		fmt.Printf("err marshal message %s of media %s : %v \n", evt.Info.ID, id, err)
+	}
+	created, err := ch.MediaFiles.Create(repository.Media{
+		ID:        id,
+		Session:   user,
+		Chat:      evt.Info.Chat.ToNonAD().String(),
+		MessageID: evt.Info.ID,
+		Type:      content.Type,
+		MimeType:  content.MimeType,
+		FileName:  content.FileName,
+		Message:   base64.StdEncoding.EncodeToString(message),
+	})
+	if err != nil {
+		fmt.Printf("err MediaFiles.Create %s : %v \n", id, err)
+		return
+	}
+	if !created {
+		return
+	}
+
+	go ch.fetchMedia(client, content.Media, id, evt.Info.ID)
+}
+
+// fetchMedia downloads a pending media and marks it as ready or failed.
+func (ch CommandHandler) fetchMedia(client *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, id, messageID string) {
+	ch.mediaSlots <- struct{}{}
+	defer func() { <-ch.mediaSlots }()
+
+	timeout := config.Conf.Media.Timeout
+	if timeout <= 0 {
+		timeout = defaultMediaTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	size, err := ch.saveMedia(ctx, client, downloadable, id)
+	if err != nil {
+		fmt.Printf("err download media %s of message %s : %v \n", id, messageID, err)
+		if errFailed := ch.MediaFiles.SetFailed(id, err.Error()); errFailed != nil {
+			fmt.Printf("err MediaFiles.SetFailed %s : %v \n", id, errFailed)
+		}
+		return
+	}
+	if err = ch.MediaFiles.SetReady(id, size); err != nil {
+		fmt.Printf("err MediaFiles.SetReady %s : %v \n", id, err)
+	}
+}
+
+// retryMedia downloads a failed media again with the session that received it, it reports false when the media
+// can not be downloaded again: the session is not connected, the message is not kept or maxMediaAttempts is reached.
+func (ch CommandHandler) retryMedia(file repository.Media) bool {
+	client, ok := ch.Sessions.Get(file.Session)
+	if !ok || !client.IsLoggedIn() || file.Message == "" || file.Attempts >= maxMediaAttempts {
+		return false
+	}
+	data, err := base64.StdEncoding.DecodeString(file.Message)
+	if err != nil {
+		return false
+	}
+	var message waProto.Message
+	if err = proto.Unmarshal(data, &message); err != nil {
+		return false
+	}
+	downloadable := parseMessageContent(&message).Media
+	if downloadable == nil {
+		return false
+	}
+
+	claimed, err := ch.MediaFiles.Retry(file.ID, maxMediaAttempts)
+	if err != nil {
+		fmt.Printf("err MediaFiles.Retry %s : %v \n", file.ID, err)
+		return false
+	}
+	if claimed {
+		go ch.fetchMedia(client, downloadable, file.ID, file.MessageID)
+	}
+	return claimed
+}
+
+// ResetPendingMedia fails the media that were still downloading when the service stopped,
+// they are downloaded again when they are requested.
+func (ch CommandHandler) ResetPendingMedia() {
+	count, err := ch.MediaFiles.FailPending(time.Now(), "the download was interrupted by a restart")
+	if err != nil {
+		fmt.Printf("err MediaFiles.FailPending : %v \n", err)
+		return
+	}
+	if count > 0 {
+		fmt.Printf("pending media marked as failed after a restart: %d \n", count)
+	}
+}
+
+// saveMedia streams the media to the media store while it is downloaded, the download is cancelled with the context
+// so a stuck download does not keep its media slot.
+func (ch CommandHandler) saveMedia(ctx context.Context, client *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, id string) (int64, error) {
+	return streamMedia(ctx, client, downloadable, func(content io.Reader) (int64, error) {
+		return ch.MediaStore.Save(ctx, id, content)
+	})
+}
+
+// OpenMedia returns the downloaded media and its content, the caller must close the content.
+// ErrMediaPending is returned while the media is downloading and ErrMediaFailed when the download failed,
+// a failed media is downloaded again when it can be and ErrMediaPending is returned instead.
+func (ch CommandHandler) OpenMedia(ctx context.Context, id string) (repository.Media, io.ReadCloser, error) {
+	file, err := ch.MediaFiles.Get(id)
+	if err != nil {
+		return file, nil, err
+	}
+	switch file.Status {
+	case repository.MediaPending:
+		return file, nil, ErrMediaPending
+	case repository.MediaFailed:
+		if ch.retryMedia(file) {
+			file.Status, file.Error = repository.MediaPending, ""
+			file.Attempts++
+			return file, nil, ErrMediaPending
+		}
+		return file, nil, fmt.Errorf("%w: %s", ErrMediaFailed, file.Error)
+	}
+
+	content, err := ch.MediaStore.Open(ctx, id)
+	if errors.Is(err, media.ErrNotFound) {
+		// the file is removed from the store, e.g. by a retention job
+		return file, nil, repository.ErrNotFound
+	}
+	return file, content, err
+}
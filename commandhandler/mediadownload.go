@@ -0,0 +1,250 @@
+package commandhandler
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/socket"
+	"go.mau.fi/whatsmeow/util/hkdfutil"
+)
+
+// mediaMACLength is the length of the truncated hmac at the end of an encrypted media
+const mediaMACLength = 10
+
+// mediaMMSTypes is the mms-type of the download url of every media type
+var mediaMMSTypes = map[whatsmeow.MediaType]string{
+	whatsmeow.MediaImage:    "image",
+	whatsmeow.MediaAudio:    "audio",
+	whatsmeow.MediaVideo:    "video",
+	whatsmeow.MediaDocument: "document",
+}
+
+// mediaHTTPClient has no timeout, a download is bounded by its context.
+var mediaHTTPClient = &http.Client{}
+
+// streamMedia downloads the media and writes it with save while it is downloaded, the media is never held in memory.
+// The download stops when the context is done, the media hosts of the session are tried one after the other.
+func streamMedia(ctx context.Context, client *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, save func(io.Reader) (int64, error)) (int64, error) {
+	mediaType := whatsmeow.GetMediaType(downloadable)
+	if mediaType == "" {
+		return 0, whatsmeow.ErrUnknownMediaType
+	}
+	urls, err := mediaDownloadURLs(client, downloadable, mediaType)
+	if err != nil {
+		return 0, err
+	}
+
+	fileLength := int64(-1)
+	if withLength, ok := downloadable.(interface{ GetFileLength() uint64 }); ok && withLength.GetFileLength() > 0 {
+		fileLength = int64(withLength.GetFileLength())
+	}
+
+	for i, url := range urls {
+		var size int64
+		size, err = downloadMediaURL(ctx, url, downloadable, mediaType, fileLength, save)
+		if err == nil || ctx.Err() != nil {
+			return size, err
+		}
+		if i < len(urls)-1 {
+			fmt.Printf("err download media from %s : %v, trying the next host \n", url, err)
+		}
+	}
+	return 0, err
+}
+
+// mediaDownloadURLs returns the urls the media can be downloaded from, the url of the message
+// or the direct path on every media host of the session.
+func mediaDownloadURLs(client *whatsmeow.Client, downloadable whatsmeow.DownloadableMessage, mediaType whatsmeow.MediaType) ([]string, error) {
+	if withURL, ok := downloadable.(interface{ GetUrl() string }); ok {
+		if url := withURL.GetUrl(); url != "" && !strings.HasPrefix(url, "https://web.whatsapp.net") {
+			return []string{url}, nil
+		}
+	}
+	if downloadable.GetDirectPath() == "" {
+		return nil, whatsmeow.ErrNoURLPresent
+	}
+
+	conn, err := client.DangerousInternals().RefreshMediaConn(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh media connections: %w", err)
+	}
+	urls := make([]string, 0, len(conn.Hosts))
+	for _, host := range conn.Hosts {
+		urls = append(urls, fmt.Sprintf("https://%s%s&hash=%s&mms-type=%s&__wa-mms=", host.Hostname, downloadable.GetDirectPath(),
+			base64.URLEncoding.EncodeToString(downloadable.GetFileEncSha256()), mediaMMSTypes[mediaType]))
+	}
+	if len(urls) == 0 {
+		return nil, whatsmeow.ErrNoURLPresent
+	}
+	return urls, nil
+}
+
+func downloadMediaURL(ctx context.Context, url string, downloadable whatsmeow.DownloadableMessage, mediaType whatsmeow.MediaType,
+	fileLength int64, save func(io.Reader) (int64, error)) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare request: %w", err)
+	}
+	req.Header.Set("Origin", socket.Origin)
+	req.Header.Set("Referer", socket.Origin+"/")
+
+	resp, err := mediaHTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, whatsmeow.DownloadHTTPError{Response: resp}
+	}
+
+	var body io.Reader = resp.Body
+	if fileLength >= 0 {
+		// the ciphertext is the padded media and the mac, anything longer is not the media
+		body = io.LimitReader(body, fileLength+aes.BlockSize+mediaMACLength+1)
+	}
+	if len(downloadable.GetMediaKey()) == 0 {
+		return save(body)
+	}
+	reader, err := newMediaDecrypter(body, downloadable.GetMediaKey(), mediaType, fileLength, downloadable.GetFileEncSha256(), downloadable.GetFileSha256())
+	if err != nil {
+		return 0, err
+	}
+	return save(reader)
+}
+
+// mediaDecrypter decrypts an encrypted media while it is read. The hashes, the mac and the length are checked
+// at the end of the media, the last read returns an error instead of io.EOF when one of them does not match
+// so a store discards what it has written.
+type mediaDecrypter struct {
+	body       io.Reader
+	mode       cipher.BlockMode
+	mac        hash.Hash
+	encHash    hash.Hash
+	plainHash  hash.Hash
+	fileLength int64
+	encSha256  []byte
+	fileSha256 []byte
+
+	// buf is read from the body, pending is the ciphertext that is not decrypted yet and out is the decrypted media
+	// that is not read yet
+	buf     []byte
+	pending []byte
+	out     []byte
+	length  int64
+	err     error
+}
+
+func newMediaDecrypter(body io.Reader, mediaKey []byte, mediaType whatsmeow.MediaType, fileLength int64, encSha256, fileSha256 []byte) (*mediaDecrypter, error) {
+	expanded := hkdfutil.SHA256(mediaKey, nil, []byte(mediaType), 112)
+	iv, cipherKey, macKey := expanded[:16], expanded[16:48], expanded[48:80]
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(iv)
+	return &mediaDecrypter{
+		body:       body,
+		mode:       cipher.NewCBCDecrypter(block, iv),
+		mac:        mac,
+		encHash:    sha256.New(),
+		plainHash:  sha256.New(),
+		fileLength: fileLength,
+		encSha256:  encSha256,
+		fileSha256: fileSha256,
+		buf:        make([]byte, 32<<10),
+	}, nil
+}
+
+func (d *mediaDecrypter) Read(p []byte) (int, error) {
+	for len(d.out) == 0 && d.err == nil {
+		n, err := d.body.Read(d.buf)
+		d.encHash.Write(d.buf[:n])
+		d.pending = append(d.pending, d.buf[:n]...)
+		switch {
+		case errors.Is(err, io.EOF):
+			d.finish()
+		case err != nil:
+			d.err = err
+		default:
+			// the last block is kept for the padding and the mac is kept for the check
+			if usable := (len(d.pending) - mediaMACLength - aes.BlockSize) / aes.BlockSize * aes.BlockSize; usable > 0 {
+				d.accept(d.decrypt(d.pending[:usable]))
+				d.pending = append(d.pending[:0], d.pending[usable:]...)
+			}
+		}
+	}
+
+	if len(d.out) > 0 {
+		n := copy(p, d.out)
+		d.out = d.out[n:]
+		return n, nil
+	}
+	return 0, d.err
+}
+
+func (d *mediaDecrypter) decrypt(ciphertext []byte) []byte {
+	d.mac.Write(ciphertext)
+	plaintext := make([]byte, len(ciphertext))
+	d.mode.CryptBlocks(plaintext, ciphertext)
+	return plaintext
+}
+
+// accept hands the decrypted media to the reader, the hash and the length cover everything that is read.
+func (d *mediaDecrypter) accept(plaintext []byte) {
+	d.plainHash.Write(plaintext)
+	d.length += int64(len(plaintext))
+	d.out = append(d.out, plaintext...)
+}
+
+// finish decrypts the last block and checks the media, d.err is io.EOF when the media is valid.
+func (d *mediaDecrypter) finish() {
+	fail := func(err error) {
+		d.out, d.err = nil, err
+	}
+
+	ciphertextLength := len(d.pending) - mediaMACLength
+	if ciphertextLength < aes.BlockSize || ciphertextLength%aes.BlockSize != 0 {
+		fail(whatsmeow.ErrTooShortFile)
+		return
+	}
+	if len(d.encSha256) == sha256.Size && !bytes.Equal(d.encHash.Sum(nil), d.encSha256) {
+		fail(whatsmeow.ErrInvalidMediaEncSHA256)
+		return
+	}
+	ciphertext, mac := d.pending[:ciphertextLength], d.pending[ciphertextLength:]
+	last := d.decrypt(ciphertext)
+	if !hmac.Equal(d.mac.Sum(nil)[:mediaMACLength], mac) {
+		fail(whatsmeow.ErrInvalidMediaHMAC)
+		return
+	}
+
+	padding := int(last[len(last)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(last[len(last)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		fail(errors.New("failed to decrypt file: invalid padding"))
+		return
+	}
+	d.accept(last[:len(last)-padding])
+	if d.fileLength >= 0 && d.length != d.fileLength {
+		fail(fmt.Errorf("%w: expected %d, got %d", whatsmeow.ErrFileLengthMismatch, d.fileLength, d.length))
+		return
+	}
+	if len(d.fileSha256) == sha256.Size && !bytes.Equal(d.plainHash.Sum(nil), d.fileSha256) {
+		fail(whatsmeow.ErrInvalidMediaSHA256)
+		return
+	}
+	d.pending, d.err = nil, io.EOF
+}
@@ -0,0 +1,88 @@
+package commandhandler
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/util/hkdfutil"
+)
+
+// encryptMedia encrypts the media the way WhatsApp does, it returns the downloaded body and its sha256.
+func encryptMedia(t *testing.T, mediaKey, media []byte, mediaType whatsmeow.MediaType) ([]byte, []byte) {
+	t.Helper()
+	expanded := hkdfutil.SHA256(mediaKey, nil, []byte(mediaType), 112)
+	iv, cipherKey, macKey := expanded[:16], expanded[16:48], expanded[48:80]
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		t.Fatalf("NewCipher: %v", err)
+	}
+
+	padding := aes.BlockSize - len(media)%aes.BlockSize
+	ciphertext := append(append([]byte{}, media...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	body := append(ciphertext, mac.Sum(nil)[:mediaMACLength]...)
+	encSha256 := sha256.Sum256(body)
+	return body, encSha256[:]
+}
+
+func TestMediaDecrypter(t *testing.T) {
+	mediaKey := bytes.Repeat([]byte{7}, 32)
+	media := bytes.Repeat([]byte("whatsapp media "), 5000)
+	fileSha256 := sha256.Sum256(media)
+	body, encSha256 := encryptMedia(t, mediaKey, media, whatsmeow.MediaImage)
+
+	tampered := append([]byte{}, body...)
+	tampered[100] ^= 1
+
+	tests := []struct {
+		name       string
+		body       io.Reader
+		mediaType  whatsmeow.MediaType
+		fileLength int64
+		encSha256  []byte
+		wantErr    error
+	}{
+		{name: "valid", body: bytes.NewReader(body), mediaType: whatsmeow.MediaImage, fileLength: int64(len(media)), encSha256: encSha256},
+		{name: "one byte reads", body: iotest.OneByteReader(bytes.NewReader(body)), mediaType: whatsmeow.MediaImage, fileLength: int64(len(media)), encSha256: encSha256},
+		{name: "unknown length", body: bytes.NewReader(body), mediaType: whatsmeow.MediaImage, fileLength: -1, encSha256: encSha256},
+		{name: "tampered", body: bytes.NewReader(tampered), mediaType: whatsmeow.MediaImage, fileLength: int64(len(media)), wantErr: whatsmeow.ErrInvalidMediaHMAC},
+		{name: "tampered with the enc hash", body: bytes.NewReader(tampered), mediaType: whatsmeow.MediaImage, fileLength: int64(len(media)), encSha256: encSha256, wantErr: whatsmeow.ErrInvalidMediaEncSHA256},
+		{name: "other media type keys", body: bytes.NewReader(body), mediaType: whatsmeow.MediaVideo, fileLength: int64(len(media)), wantErr: whatsmeow.ErrInvalidMediaHMAC},
+		{name: "length", body: bytes.NewReader(body), mediaType: whatsmeow.MediaImage, fileLength: int64(len(media)) + 1, wantErr: whatsmeow.ErrFileLengthMismatch},
+		{name: "truncated", body: bytes.NewReader(body[:len(body)-20]), mediaType: whatsmeow.MediaImage, fileLength: int64(len(media)), wantErr: whatsmeow.ErrTooShortFile},
+		{name: "too short", body: bytes.NewReader(body[:8]), mediaType: whatsmeow.MediaImage, fileLength: -1, wantErr: whatsmeow.ErrTooShortFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := newMediaDecrypter(tt.body, mediaKey, tt.mediaType, tt.fileLength, tt.encSha256, fileSha256[:])
+			if err != nil {
+				t.Fatalf("newMediaDecrypter: %v", err)
+			}
+			got, err := io.ReadAll(reader)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if !bytes.Equal(got, media) {
+				t.Fatalf("decrypted %d bytes, want the %d bytes of the media", len(got), len(media))
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package commandhandler
+
+import (
+	"fmt"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// receiptStatus is the outbound status of every receipt type sent by the recipient,
+// the receipts of our own devices (sender, read-self, played-self) are not a delivery status.
+var receiptStatus = map[types.ReceiptType]string{
+	types.ReceiptTypeDelivered:   repository.StatusDelivered,
+	types.ReceiptTypeRead:        repository.StatusRead,
+	types.ReceiptTypePlayed:      repository.StatusPlayed,
+	types.ReceiptTypeServerError: repository.StatusFailed,
+}
+
+// logOutbound records a message before it is sent, a failure is only printed so the message is still sent.
+func (ch CommandHandler) logOutbound(msg repository.OutboundMessage) {
+	if ch.Messages == nil {
+		return
+	}
+	err := ch.Messages.CreateOutbound(msg)
+	if err != nil {
+		fmt.Printf("err Messages.CreateOutbound %s : %v \n", msg.MessageID, err)
+	}
+}
+
+// logOutboundResult marks the message as sent or failed based on the result of SendMessage.
+func (ch CommandHandler) logOutboundResult(sender, messageID string, resp whatsmeow.SendResponse, sendErr error) {
+	if ch.Messages == nil {
+		return
+	}
+
+	status, at, errMsg := repository.StatusSent, resp.Timestamp, ""
+	if sendErr != nil {
+		status, at, errMsg = repository.StatusFailed, time.Now(), sendErr.Error()
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	_, err := ch.Messages.UpdateOutboundStatus(sender, messageID, status, at, errMsg)
+	if err != nil {
+		fmt.Printf("err Messages.UpdateOutboundStatus %s : %v \n", messageID, err)
+	}
+}
+
+// messageEventHandler stores the received messages of the session, answers them with the bot commands,
+// the auto-reply rules and the away message, and updates the outbound message log from the receipts of the recipients.
+func (ch CommandHandler) messageEventHandler(requestedUser string, client *whatsmeow.Client) whatsmeow.EventHandler {
+	return func(evt interface{}) {
+		switch v := evt.(type) {
+		case *events.Message:
+			user := ch.sessionKey(requestedUser, client)
+			ch.logInbound(user, client, v)
+			// the replies are sent outside of the event loop, sending waits for the server response
+			go func() {
+				if ch.botCommand(user, v) {
+					return
+				}
+				ch.autoReply(user, v)
+				ch.awayReply(user, v)
+			}()
+		case *events.Receipt:
+			status, ok := receiptStatus[v.Type]
+			if !ok || v.IsFromMe || ch.Messages == nil {
+				return
+			}
+
+			user := ch.sessionKey(requestedUser, client)
+			errMsg := ""
+			if status == repository.StatusFailed {
+				errMsg = fmt.Sprintf("server error receipt from %s", v.Sender)
+			}
+			for _, messageID := range v.MessageIDs {
+				found, err := ch.Messages.UpdateOutboundStatus(user, messageID, status, v.Timestamp, errMsg)
+				if err != nil {
+					fmt.Printf("err Messages.UpdateOutboundStatus %s : %v \n", messageID, err)
+				}
+
+				// the message status is the first receipt of the group, every participant is kept as well
+				if found && v.IsGroup && status != repository.StatusFailed {
+					err = ch.Messages.RecordReceipt(user, messageID, v.Sender.ToNonAD().String(), status, v.Timestamp)
+					if err != nil {
+						fmt.Printf("err Messages.RecordReceipt %s : %v \n", messageID, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// MessageStatus returns the logged outbound message with its delivery status, sender is optional.
+func (ch CommandHandler) MessageStatus(sender, messageID string) (repository.OutboundMessage, error) {
+	return ch.Messages.GetOutbound(sender, messageID)
+}
+
+// MessageStatuses returns the logged outbound messages of the ids and the ids that are not on the log.
+func (ch CommandHandler) MessageStatuses(sender string, messageIDs []string) (messages []repository.OutboundMessage, notFound []string, err error) {
+	messages, err = ch.Messages.GetOutboundMany(sender, messageIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		found[msg.MessageID] = true
+	}
+	notFound = []string{}
+	for _, id := range messageIDs {
+		if !found[id] {
+			notFound = append(notFound, id)
+		}
+	}
+	return messages, notFound, nil
+}
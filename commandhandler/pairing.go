@@ -0,0 +1,321 @@
+package commandhandler
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/session"
+
+	"github.com/mdp/qrterminal/v3"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	// pairClientDisplayName must be formatted as `Browser (OS)`, it is validated by whatsapp
+	pairClientDisplayName = "Chrome (Linux)"
+
+	// pairingEventBuffer is big enough to hold every qr code of a pairing,
+	// events are dropped when nobody is reading them anymore
+	pairingEventBuffer = 16
+)
+
+const (
+	PairingMismatchReject = "reject"
+	PairingMismatchRekey  = "rekey"
+
+	// pairingLoginTimeout is how long a rejected pairing waits for the login before the device is logged out
+	pairingLoginTimeout = 15 * time.Second
+)
+
+var (
+	ErrAlreadyLogin  = errors.New("you are already login")
+	ErrPairingClosed = errors.New("pairing is closed before the qr code is generated")
+)
+
+// Pairing is a login attempt of a sender that has not been paired yet.
+type Pairing struct {
+	User      string
+	Client    *whatsmeow.Client
+	StartedAt time.Time
+	ExpiresAt time.Time
+	// Events emits every qr code and then a final success, timeout or error event before it is closed
+	Events <-chan primitive.PairingEvent
+}
+
+// StartPairing connects a new client for the jid and starts emitting the pairing events.
+// the client is registered right away, and it is disconnected and removed again when the pairing does not succeed.
+// when the jid is already paired the stored session is connected and ErrAlreadyLogin is returned.
+func (ch CommandHandler) StartPairing(ctx context.Context, jid types.JID) (*Pairing, error) {
+	// the running session is kept, a second client for the same device would replace its stream
+	if current, ok := ch.Sessions.Get(jid.User); ok && current.IsLoggedIn() {
+		return nil, ErrAlreadyLogin
+	}
+
+	device, err := ch.findDevice(jid)
+	if err != nil {
+		return nil, err
+	}
+
+	client := ch.newClient(jid.User, device)
+
+	if client.Store.ID != nil {
+		ch.Sessions.SetState(jid.User, session.StateConnecting, nil)
+		err = client.Connect()
+		if err != nil {
+			ch.supervisors.stop(client)
+			ch.Sessions.SetState(jid.User, session.StateDisconnected, err)
+			return nil, err
+		}
+		ch.Sessions.Put(jid.User, client)
+		return nil, ErrAlreadyLogin
+	}
+
+	// the paired jid is captured before the qr channel emits the success item
+	paired := make(chan types.JID, 1)
+	client.AddEventHandler(func(evt interface{}) {
+		if v, ok := evt.(*events.PairSuccess); ok {
+			select {
+			case paired <- v.ID:
+			default:
+			}
+		}
+	})
+
+	expiry := config.Conf.Pairing.Expiry
+	if expiry <= 0 {
+		expiry = defaultPairingExpiry
+	}
+	// the qr channel stops and disconnects the client once the pairing is expired
+	ctx, cancel := context.WithTimeout(ctx, expiry)
+
+	qrChan, err := client.GetQRChannel(ctx)
+	if err != nil {
+		cancel()
+		ch.supervisors.stop(client)
+		return nil, err
+	}
+
+	// only one pairing per sender, the previous one is abandoned by requesting a new qr
+	startedAt := time.Now()
+	previous := ch.pairings.add(jid.User, &pendingPairing{client: client, startedAt: startedAt, expiresAt: startedAt.Add(expiry)})
+	if previous != nil {
+		ch.discardPairing(jid.User, previous.client, errors.New("pairing is replaced by a new request"))
+	}
+
+	ch.Sessions.SetState(jid.User, session.StatePairing, nil)
+	err = client.Connect()
+	if err != nil {
+		cancel()
+		ch.supervisors.stop(client)
+		ch.pairings.remove(jid.User, client)
+		ch.Sessions.SetState(jid.User, session.StateDisconnected, err)
+		return nil, err
+	}
+	// Add the client to the map
+	ch.Sessions.Put(jid.User, client)
+
+	events := make(chan primitive.PairingEvent, pairingEventBuffer)
+	go func() {
+		defer cancel()
+		defer ch.pairings.remove(jid.User, client)
+		ch.forwardPairing(jid.User, client, qrChan, paired, events)
+	}()
+
+	return &Pairing{
+		User:      jid.User,
+		Client:    client,
+		StartedAt: startedAt,
+		ExpiresAt: startedAt.Add(expiry),
+		Events:    events,
+	}, nil
+}
+
+// forwardPairing converts the whatsmeow qr channel into pairing events, and cleans up the client when the pairing fails.
+func (ch CommandHandler) forwardPairing(user string, client *whatsmeow.Client, qrChan <-chan whatsmeow.QRChannelItem, paired <-chan types.JID, events chan<- primitive.PairingEvent) {
+	defer close(events)
+
+	emit := func(evt primitive.PairingEvent) {
+		select {
+		case events <- evt:
+		default:
+			// nobody is reading the events anymore
+		}
+	}
+
+	fmt.Println("Waiting for QR code or login event...")
+	for evt := range qrChan {
+		switch evt.Event {
+		case whatsmeow.QRChannelEventCode:
+			fmt.Println("QR code:", evt.Code)
+			qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+			pairingEvent := primitive.PairingEvent{
+				Event:   primitive.PairingEventCode,
+				Code:    evt.Code,
+				Timeout: int(evt.Timeout.Seconds()),
+			}
+			// the raw code is still usable when the png can not be generated
+			image, err := generateQRCode(evt.Code)
+			if err != nil {
+				fmt.Println("Error generating QR code:", err)
+			} else {
+				pairingEvent.Image = base64.StdEncoding.EncodeToString(image)
+			}
+			emit(pairingEvent)
+		case whatsmeow.QRChannelSuccess.Event:
+			fmt.Println("Login event:", evt.Event)
+			emit(ch.verifyPairing(user, client, paired))
+			return
+		case whatsmeow.QRChannelTimeout.Event:
+			fmt.Println("Login event:", evt.Event)
+			ch.discardPairing(user, client, errors.New("pairing timeout, the qr code is not scanned"))
+			emit(primitive.PairingEvent{Event: primitive.PairingEventTimeout})
+			return
+		default:
+			fmt.Println("Login event:", evt.Event)
+			errPairing := fmt.Errorf("pairing failed: %s", evt.Event)
+			if evt.Error != nil {
+				errPairing = fmt.Errorf("pairing failed: %v", evt.Error)
+			}
+			ch.discardPairing(user, client, errPairing)
+			emit(primitive.PairingEvent{Event: primitive.PairingEventError, Error: errPairing.Error()})
+			return
+		}
+	}
+
+	// the channel is closed without a final event when the context is done
+	ch.discardPairing(user, client, errors.New("pairing is cancelled"))
+	emit(primitive.PairingEvent{Event: primitive.PairingEventTimeout})
+}
+
+// verifyPairing compares the paired jid with the requested sender, a mismatch is either re-keyed to the
+// scanned number or rejected and the device is removed, based on config pairing.onMismatch.
+func (ch CommandHandler) verifyPairing(user string, client *whatsmeow.Client, paired <-chan types.JID) primitive.PairingEvent {
+	var pairedJID types.JID
+	select {
+	case pairedJID = <-paired:
+	default:
+		if client.Store.ID != nil {
+			pairedJID = *client.Store.ID
+		}
+	}
+
+	if pairedJID.User == "" || pairedJID.User == user {
+		return primitive.PairingEvent{Event: primitive.PairingEventSuccess, JID: pairedJID.ToNonAD().String()}
+	}
+
+	errMismatch := fmt.Errorf("scanned phone %s does not match the requested sender %s", pairedJID.User, user)
+	fmt.Println(errMismatch)
+
+	if config.Conf.Pairing.OnMismatch == PairingMismatchRekey {
+		ch.rekeyPairing(user, pairedJID.User, client, errMismatch)
+		return primitive.PairingEvent{
+			Event:   primitive.PairingEventSuccess,
+			JID:     pairedJID.ToNonAD().String(),
+			Message: fmt.Sprintf("%s, the session is registered as %s", errMismatch.Error(), pairedJID.User),
+		}
+	}
+
+	go ch.rejectPairing(user, client, errMismatch)
+	return primitive.PairingEvent{
+		Event: primitive.PairingEventMismatch,
+		JID:   pairedJID.ToNonAD().String(),
+		Error: fmt.Sprintf("%s, the device is removed", errMismatch.Error()),
+	}
+}
+
+// rekeyPairing moves the client from the requested sender to the paired number.
+func (ch CommandHandler) rekeyPairing(user, pairedUser string, client *whatsmeow.Client, reason error) {
+	if previous, ok := ch.Sessions.Get(pairedUser); ok && previous != client {
+		previous.Disconnect()
+	}
+	// registered under the paired number first, so the client keeps its supervisor
+	ch.Sessions.Put(pairedUser, client)
+	ch.Sessions.CompareAndDelete(user, client)
+	ch.Sessions.SetState(user, session.StateDisconnected, reason)
+	ch.Sessions.SetState(pairedUser, session.StateConnecting, nil)
+}
+
+// rejectPairing logs out the device paired with the wrong phone and removes it from the store.
+func (ch CommandHandler) rejectPairing(user string, client *whatsmeow.Client, reason error) {
+	// the client reconnects right after the pairing, logout only works once it is logged in
+	deadline := time.Now().Add(pairingLoginTimeout)
+	for !client.IsLoggedIn() && time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	err := client.Logout()
+	if err != nil {
+		fmt.Printf("err client.Logout on rejected pairing : %v \n", err)
+		client.Disconnect()
+		if client.Store.ID != nil {
+			if errDelete := client.Store.Delete(); errDelete != nil {
+				fmt.Printf("err store.Delete on rejected pairing : %v \n", errDelete)
+			}
+		}
+	}
+	ch.discardPairing(user, client, reason)
+	ch.Sessions.SetState(user, session.StateDisconnected, reason)
+}
+
+// discardPairing disconnects the client of a failed pairing and removes it from the registry.
+func (ch CommandHandler) discardPairing(user string, client *whatsmeow.Client, reason error) {
+	ch.supervisors.stop(client)
+	client.Disconnect()
+	if ch.Sessions.CompareAndDelete(user, client) {
+		ch.Sessions.SetState(user, session.StateDisconnected, reason)
+	}
+}
+
+// firstQRCode waits for the first qr code of the pairing,
+// the remaining events are drained in the background so the pairing keeps running until it is finished.
+func firstQRCode(pairing *Pairing) (string, error) {
+	for evt := range pairing.Events {
+		switch evt.Event {
+		case primitive.PairingEventCode:
+			go func() {
+				for range pairing.Events {
+				}
+			}()
+			return evt.Code, nil
+		case primitive.PairingEventError:
+			return "", errors.New(evt.Error)
+		case primitive.PairingEventSuccess:
+			return "", ErrAlreadyLogin
+		}
+	}
+	return "", ErrPairingClosed
+}
+
+// GetPairCode starts a session for the jid and returns the 8 character pairing code that should be
+// entered on the phone (Linked devices > Link with phone number instead) as an alternative to scanning the QR.
+// the code is empty when the jid is already paired, in that case the stored session is connected.
+func (ch CommandHandler) GetPairCode(ctx context.Context, jid types.JID) (string, error) {
+	pairing, err := ch.StartPairing(ctx, jid)
+	if errors.Is(err, ErrAlreadyLogin) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the websocket is ready to pair once the first qr code is emitted
+	_, err = firstQRCode(pairing)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := pairing.Client.PairPhone(jid.User, true, whatsmeow.PairClientChrome, pairClientDisplayName)
+	if err != nil {
+		ch.discardPairing(jid.User, pairing.Client, err)
+		return "", err
+	}
+	return code, nil
+}
@@ -0,0 +1,131 @@
+package commandhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/repository"
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// maxReactionRunes bounds a reaction, an emoji with its modifiers and joiners is a few runes long
+const maxReactionRunes = 16
+
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+// HandleSendReaction reacts to a message of the chat, an empty reaction removes the reaction of the sender.
+// participant is the sender of the message, it is taken from the message log when it is empty.
+func (ch CommandHandler) HandleSendReaction(sender types.JID, chat, messageID, participant, reaction string) (string, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidReaction, fmt.Sprintf(format, args...))
+	}
+
+	chatJID, ok := ParseJID(chat)
+	if !ok {
+		return "", invalid("chat %q is not a valid jid", chat)
+	}
+	if messageID == "" {
+		return "", invalid("messageId should be filled")
+	}
+	if utf8.RuneCountInString(reaction) > maxReactionRunes || strings.IndexFunc(reaction, unicode.IsSpace) >= 0 {
+		return "", invalid("reaction should be a single emoji")
+	}
+
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return "", session.ErrSessionNotFound
+	}
+
+	target, err := ch.messageSender(sender, chatJID, messageID, participant)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidReaction, err)
+	}
+
+	reactionID := client.GenerateMessageID()
+	ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: reactionID, Recipient: chatJID.String(), Type: MessageTypeReaction, Body: reaction})
+	msg := client.BuildReaction(chatJID, target, messageID, reaction)
+	resp, err := client.SendMessage(context.Background(), chatJID, msg, whatsmeow.SendRequestExtra{ID: reactionID})
+	ch.logOutboundResult(sender.User, reactionID, resp, err)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// messageSender returns the sender of a message of the chat, participant is used when it is set, otherwise the
+// sender is taken from the message log. A message of a private chat that is not logged is taken as sent by the contact,
+// a message of a group that is not logged needs the participant.
+func (ch CommandHandler) messageSender(sender, chat types.JID, messageID, participant string) (types.JID, error) {
+	if participant == "" && ch.Messages != nil {
+		entry, err := ch.Messages.GetChatMessage(sender.User, chat.ToNonAD().String(), messageID)
+		switch {
+		case err == nil:
+			participant = entry.Sender
+		case !errors.Is(err, repository.ErrNotFound):
+			fmt.Printf("err Messages.GetChatMessage %s : %v \n", messageID, err)
+		}
+	}
+
+	if participant == "" {
+		if chat.Server == types.GroupServer {
+			return types.EmptyJID, errors.New("participant is required for a message of a group that is not logged")
+		}
+		return chat.ToNonAD(), nil
+	}
+	participantJID, ok := ParseJID(participant)
+	if !ok {
+		return types.EmptyJID, fmt.Errorf("participant %q is not a valid jid", participant)
+	}
+	return participantJID.ToNonAD(), nil
+}
+
+// reactionEventData returns the data of a reaction event, ok is false when the message is not a reaction.
+// The key of the reaction is from the point of view of the reactor, FromMe is set for a message of the reactor.
+func reactionEventData(user string, evt *events.Message) (data primitive.ReactionEvent, ok bool) {
+	reaction := evt.Message.GetReactionMessage()
+	if reaction == nil || evt.Info.Chat == types.StatusBroadcastJID {
+		return data, false
+	}
+	key := reaction.GetKey()
+
+	targetIsFromMe := key.GetFromMe() == evt.Info.IsFromMe
+	targetSender := key.GetParticipant()
+	switch {
+	case key.GetFromMe():
+		targetSender = evt.Info.Sender.ToNonAD().String()
+	case targetSender != "":
+		targetIsFromMe = false
+		if jid, ok := ParseJID(targetSender); ok {
+			targetSender = jid.ToNonAD().String()
+			targetIsFromMe = jid.User == user
+		}
+	case targetIsFromMe:
+		targetSender = types.NewJID(user, types.DefaultUserServer).String()
+	default:
+		targetSender = evt.Info.Chat.ToNonAD().String()
+	}
+
+	return primitive.ReactionEvent{
+		MessageID:       evt.Info.ID,
+		Chat:            evt.Info.Chat.ToNonAD().String(),
+		Sender:          evt.Info.Sender.ToNonAD().String(),
+		PushName:        evt.Info.PushName,
+		IsGroup:         evt.Info.IsGroup,
+		IsFromMe:        evt.Info.IsFromMe,
+		TargetMessageID: key.GetId(),
+		TargetSender:    targetSender,
+		TargetIsFromMe:  targetIsFromMe,
+		Reaction:        reaction.GetText(),
+		Removed:         reaction.GetText() == "",
+		Timestamp:       evt.Info.Timestamp,
+	}, true
+}
@@ -0,0 +1,78 @@
+package commandhandler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// sendToRecipients sends the message to every recipient concurrently the same way the media is sent,
+// msgType and body are what the message log records for it.
+func (ch CommandHandler) sendToRecipients(sender types.JID, JIDS []string, msgType, body string, msg *waProto.Message) ([]Message, error) {
+	client, ok := ch.Sessions.Get(sender.User)
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var sliceM []Message
+	var errs []error
+
+	for _, jid := range JIDS {
+		wg.Add(1)
+		go func(jid string) {
+			defer wg.Done()
+
+			recipient, ok := ParseJID(jid)
+			if !ok {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("invalid JID: %s", jid))
+				mu.Unlock()
+				return
+			}
+
+			err := client.SendPresence(types.PresenceAvailable)
+			if err != nil {
+				fmt.Printf("err sending presence: %v \n", err)
+			}
+
+			messageID := client.GenerateMessageID()
+			ch.logOutbound(repository.OutboundMessage{Sender: sender.User, MessageID: messageID, Recipient: recipient.String(), Type: msgType, Body: body})
+
+			resp, err := client.SendMessage(context.Background(), recipient, msg, whatsmeow.SendRequestExtra{ID: messageID})
+			ch.logOutboundResult(sender.User, messageID, resp, err)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("error sending %s message: %v", msgType, err))
+				mu.Unlock()
+				return
+			}
+
+			err = client.MarkRead([]types.MessageID{resp.ID}, time.Now(), recipient, sender)
+			if err != nil {
+				fmt.Printf("err sending MarkRead: %v \n", err)
+			}
+
+			m := Message{resp.ID, recipient.String(), msgType, body, true, ""}
+			mu.Lock()
+			sliceM = append(sliceM, m)
+			mu.Unlock()
+		}(jid)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return sliceM, nil
+}
@@ -0,0 +1,76 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// newClient creates the whatsmeow client for the device with every event handler of the session attached.
+// user is the key of the session on the registry, it is the requested sender when the device is not paired yet.
+func (ch CommandHandler) newClient(user string, device *store.Device) *whatsmeow.Client {
+	clientLog := waLog.Stdout("Client", "DEBUG", true)
+	client := whatsmeow.NewClient(device, clientLog)
+	client.AddEventHandler(EventHandler)
+	client.AddEventHandler(ch.stateEventHandler(user, client))
+	client.AddEventHandler(ch.messageEventHandler(user, client))
+	client.AddEventHandler(ch.publishEventHandler(user, client))
+	client.AddEventHandler(ch.callEventHandler(user, client))
+	ch.supervise(user, client)
+	return client
+}
+
+// sessionKey returns the key of the client on the registry, it is the paired number once the client
+// is registered under it (e.g. after a re-key), otherwise the user the client was created for.
+func (ch CommandHandler) sessionKey(user string, client *whatsmeow.Client) string {
+	if client.Store.ID == nil || client.Store.ID.User == user {
+		return user
+	}
+	if current, ok := ch.Sessions.Get(client.Store.ID.User); ok && current == client {
+		return client.Store.ID.User
+	}
+	return user
+}
+
+// stateEventHandler drives the lifecycle state of the session based on the whatsmeow events.
+func (ch CommandHandler) stateEventHandler(requestedUser string, client *whatsmeow.Client) whatsmeow.EventHandler {
+	return func(evt interface{}) {
+		user := ch.sessionKey(requestedUser, client)
+		switch v := evt.(type) {
+		case *events.PairSuccess:
+			ch.Sessions.SetState(user, session.StateConnecting, nil)
+		case *events.PairError:
+			ch.Sessions.SetState(user, session.StatePairing, v.Error)
+		case *events.Connected:
+			ch.Sessions.SetState(user, session.StateConnected, nil)
+		case *events.Disconnected:
+			ch.Sessions.SetState(user, session.StateDisconnected, nil)
+		case *events.StreamReplaced:
+			ch.Sessions.SetState(user, session.StateDisconnected, errors.New(v.PermanentDisconnectDescription()))
+		case *events.ConnectFailure:
+			ch.Sessions.SetState(user, session.StateDisconnected, fmt.Errorf("connect failure: %s %s", v.Reason.String(), v.Message))
+		case *events.ClientOutdated:
+			ch.Sessions.SetState(user, session.StateDisconnected, errors.New(v.PermanentDisconnectDescription()))
+		case *events.LoggedOut:
+			ch.Sessions.SetState(user, session.StateLoggedOut, fmt.Errorf("logged out: %s", v.PermanentDisconnectDescription()))
+		case *events.TemporaryBan:
+			ch.Sessions.SetState(user, session.StateBanned, errors.New(v.String()))
+		}
+	}
+}
+
+// SessionStatus returns the tracked status of the user,
+// a session that was never tracked on this instance is reported as disconnected.
+func (ch CommandHandler) SessionStatus(user string) session.Status {
+	status, ok := ch.Sessions.Status(user)
+	if !ok {
+		return session.Status{State: session.StateDisconnected, History: []session.Transition{}}
+	}
+	return status
+}
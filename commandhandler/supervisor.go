@@ -0,0 +1,197 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/session"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	defaultInitialBackoff     = 2 * time.Second
+	defaultMaxBackoff         = 5 * time.Minute
+	defaultKeepAliveMaxErrors = 3
+)
+
+// supervisor keeps a single session connected, it reconnects the client with exponential backoff and jitter
+// when the connection is lost, instead of the built-in whatsmeow auto reconnect.
+type supervisor struct {
+	ch     CommandHandler
+	user   string
+	client *whatsmeow.Client
+
+	running  atomic.Bool
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// supervisorSet keeps the supervisor of every client.
+type supervisorSet struct {
+	mu          sync.Mutex
+	supervisors map[*whatsmeow.Client]*supervisor
+}
+
+func newSupervisorSet() *supervisorSet {
+	return &supervisorSet{
+		supervisors: make(map[*whatsmeow.Client]*supervisor),
+	}
+}
+
+func (s *supervisorSet) add(sup *supervisor) {
+	s.mu.Lock()
+	s.supervisors[sup.client] = sup
+	s.mu.Unlock()
+}
+
+func (s *supervisorSet) get(client *whatsmeow.Client) (*supervisor, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sup, ok := s.supervisors[client]
+	return sup, ok
+}
+
+// stop stops the supervisor of the client, a stopped client is not reconnected anymore.
+func (s *supervisorSet) stop(client *whatsmeow.Client) {
+	s.mu.Lock()
+	sup, ok := s.supervisors[client]
+	delete(s.supervisors, client)
+	s.mu.Unlock()
+	if ok {
+		sup.stop()
+	}
+}
+
+// supervise attaches a supervisor to the client, user is the key the client is created for.
+func (ch CommandHandler) supervise(user string, client *whatsmeow.Client) *supervisor {
+	// reconnection is done by the supervisor
+	client.EnableAutoReconnect = false
+
+	sup := &supervisor{
+		ch:      ch,
+		user:    user,
+		client:  client,
+		stopped: make(chan struct{}),
+	}
+	client.AddEventHandler(sup.handleEvent)
+	ch.supervisors.add(sup)
+	return sup
+}
+
+// handleEvent reacts on the connection events, only paired clients are reconnected,
+// a client that is pairing is handled by the pairing flow.
+func (s *supervisor) handleEvent(evt interface{}) {
+	switch v := evt.(type) {
+	case *events.Disconnected:
+		s.start(errors.New("connection is lost"), true)
+	case *events.StreamReplaced:
+		s.start(errors.New("stream replaced by another connection"), true)
+	case *events.KeepAliveTimeout:
+		maxErrors := config.Conf.Reconnect.KeepAliveMaxErrors
+		if maxErrors <= 0 {
+			maxErrors = defaultKeepAliveMaxErrors
+		}
+		if v.ErrorCount >= maxErrors {
+			// the websocket is probably dead, force a new connection instead of waiting for the tcp timeout
+			s.client.Disconnect()
+			s.start(fmt.Errorf("keepalive timeout %d times since %s", v.ErrorCount, v.LastSuccess.Format(time.RFC3339)), true)
+		}
+	case *events.LoggedOut:
+		s.ch.supervisors.stop(s.client)
+	}
+}
+
+// start connects the client in the background until it succeeds, it does nothing when it is already running.
+func (s *supervisor) start(reason error, isReconnect bool) {
+	if s.isStopped() || s.client.Store.ID == nil {
+		return
+	}
+	if !s.running.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.running.Store(false)
+		s.connect(reason, isReconnect)
+	}()
+}
+
+func (s *supervisor) stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+}
+
+func (s *supervisor) isStopped() bool {
+	select {
+	case <-s.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// connect retries the connection with exponential backoff and jitter,
+// the first attempt of the initial connection is done without waiting.
+func (s *supervisor) connect(reason error, isReconnect bool) {
+	maxAttempts := config.Conf.Reconnect.MaxAttempts
+	for attempt := 0; maxAttempts <= 0 || attempt < maxAttempts; attempt++ {
+		user := s.ch.sessionKey(s.user, s.client)
+
+		if attempt > 0 || isReconnect {
+			delay := backoff(attempt)
+			fmt.Printf("session %s reconnect attempt %d in %s: %v \n", user, attempt+1, delay, reason)
+			select {
+			case <-s.stopped:
+				return
+			case <-time.After(delay):
+			}
+		}
+
+		if s.isStopped() || s.client.Store.ID == nil {
+			return
+		}
+		if s.client.IsConnected() {
+			return
+		}
+
+		s.ch.Sessions.SetState(user, session.StateConnecting, nil)
+		err := s.client.Connect()
+		if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			return
+		}
+		reason = err
+		s.ch.Sessions.SetState(user, session.StateDisconnected, fmt.Errorf("connect attempt %d failed: %w", attempt+1, err))
+	}
+	fmt.Printf("session %s is not reconnected after %d attempts: %v \n", s.ch.sessionKey(s.user, s.client), maxAttempts, reason)
+}
+
+// backoff returns the delay before the attempt, it doubles on every attempt up to the max backoff
+// and a random jitter of up to half of the delay is applied so the sessions do not reconnect at the same time.
+func backoff(attempt int) time.Duration {
+	initial := config.Conf.Reconnect.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	maxBackoff := config.Conf.Reconnect.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	delay := initial
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,37 @@
+package commandhandler
+
+import (
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    config.Reconnect
+		attempt int
+		delay   time.Duration
+	}{
+		{name: "first attempt", conf: config.Reconnect{InitialBackoff: time.Second, MaxBackoff: time.Minute}, attempt: 0, delay: time.Second},
+		{name: "doubles", conf: config.Reconnect{InitialBackoff: time.Second, MaxBackoff: time.Minute}, attempt: 3, delay: 8 * time.Second},
+		{name: "max backoff", conf: config.Reconnect{InitialBackoff: time.Second, MaxBackoff: time.Minute}, attempt: 10, delay: time.Minute},
+		{name: "many attempts", conf: config.Reconnect{InitialBackoff: time.Second, MaxBackoff: time.Minute}, attempt: 1000, delay: time.Minute},
+		{name: "defaults", attempt: 1, delay: 2 * defaultInitialBackoff},
+		{name: "default max backoff", attempt: 100, delay: defaultMaxBackoff},
+	}
+	previous := config.Conf.Reconnect
+	t.Cleanup(func() { config.Conf.Reconnect = previous })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Conf.Reconnect = tt.conf
+			// the jitter is random, the delay is between half of the delay and the delay
+			for i := 0; i < 50; i++ {
+				if got := backoff(tt.attempt); got < tt.delay/2 || got > tt.delay {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.delay/2, tt.delay)
+				}
+			}
+		})
+	}
+}
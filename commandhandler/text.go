@@ -0,0 +1,92 @@
+package commandhandler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"whatsapp_multi_session_general/repository"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrInvalidTextOptions = errors.New("invalid text options")
+
+// TextOptions are the optional parts of an outgoing text message.
+type TextOptions struct {
+	// QuotedMessageID is the message the text replies to, QuotedParticipant is its sender.
+	// the participant is taken from the message log when it is empty, it is needed for a message that is not logged on a group
+	QuotedMessageID   string
+	QuotedParticipant string
+	// Mentions are the mentioned contacts, the text mentions them as @<number>
+	Mentions []string
+}
+
+func (opts TextOptions) isEmpty() bool {
+	return opts.QuotedMessageID == "" && len(opts.Mentions) == 0
+}
+
+// createTextMessage builds a plain text message, or an extended text with the context info of the options.
+func (ch CommandHandler) createTextMessage(sender, recipient types.JID, textMsg string, opts TextOptions) (*waProto.Message, error) {
+	if opts.isEmpty() {
+		return &waProto.Message{Conversation: proto.String(textMsg)}, nil
+	}
+
+	contextInfo := &waProto.ContextInfo{}
+	for _, mention := range opts.Mentions {
+		mention = strings.TrimSpace(mention)
+		if mention == "" {
+			return nil, fmt.Errorf("%w: mention is empty", ErrInvalidTextOptions)
+		}
+		jid, ok := ParseJID(mention)
+		if !ok {
+			return nil, fmt.Errorf("%w: mention %q is not a valid jid", ErrInvalidTextOptions, mention)
+		}
+		contextInfo.MentionedJid = append(contextInfo.MentionedJid, jid.ToNonAD().String())
+	}
+
+	if opts.QuotedMessageID != "" {
+		contextInfo.StanzaId = proto.String(opts.QuotedMessageID)
+		participant := opts.QuotedParticipant
+
+		// the quoted text is shown on the reply, it is rebuilt from the message log for a logged text message.
+		// any other message is referenced by its id and participant only, the log does not keep enough of it
+		// to rebuild it and a partial quoted message is shown as an empty quote
+		if ch.Messages != nil {
+			quoted, err := ch.Messages.GetChatMessage(sender.User, recipient.ToNonAD().String(), opts.QuotedMessageID)
+			switch {
+			case err == nil:
+				if participant == "" {
+					participant = quoted.Sender
+				}
+				if quoted.Type == MessageTypeText && quoted.Text != "" {
+					contextInfo.QuotedMessage = &waProto.Message{Conversation: proto.String(quoted.Text)}
+				}
+			case !errors.Is(err, repository.ErrNotFound):
+				fmt.Printf("err Messages.GetChatMessage %s : %v \n", opts.QuotedMessageID, err)
+			}
+		}
+
+		if participant == "" {
+			if recipient.Server == types.GroupServer {
+				return nil, fmt.Errorf("%w: quotedParticipant is required to quote a message of a group that is not logged", ErrInvalidTextOptions)
+			}
+			// the quoted message of a private chat is sent by the contact
+			participant = recipient.ToNonAD().String()
+		}
+		participantJID, ok := ParseJID(participant)
+		if !ok {
+			return nil, fmt.Errorf("%w: quotedParticipant %q is not a valid jid", ErrInvalidTextOptions, participant)
+		}
+		contextInfo.Participant = proto.String(participantJID.ToNonAD().String())
+	}
+
+	return &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text:        proto.String(textMsg),
+			ContextInfo: contextInfo,
+		},
+	}, nil
+}
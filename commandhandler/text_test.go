@@ -0,0 +1,80 @@
+package commandhandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestCreateTextMessageQuote(t *testing.T) {
+	messages := repository.NewMessageRepository(newTestDB(t))
+	ch := CommandHandler{Messages: messages}
+
+	sender := types.NewJID("6281", types.DefaultUserServer)
+	contact := types.NewJID("6282", types.DefaultUserServer)
+	group := types.NewJID("1203", types.GroupServer)
+	for _, msg := range []repository.InboundMessage{
+		{MessageID: "TEXT", Type: MessageTypeText, Text: "where is my order?"},
+		{MessageID: "IMAGE", Type: MessageTypeImage, Text: "a caption", MimeType: "image/jpeg"},
+	} {
+		msg.Session, msg.Chat, msg.Sender, msg.Timestamp = "6281", contact.String(), contact.String(), time.Now()
+		if err := messages.CreateInbound(msg); err != nil {
+			t.Fatalf("CreateInbound: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		recipient       types.JID
+		opts            TextOptions
+		wantQuotedText  string
+		wantParticipant string
+		wantErr         bool
+	}{
+		{name: "logged text", recipient: contact, opts: TextOptions{QuotedMessageID: "TEXT"}, wantQuotedText: "where is my order?", wantParticipant: contact.String()},
+		{name: "logged media", recipient: contact, opts: TextOptions{QuotedMessageID: "IMAGE"}, wantParticipant: contact.String()},
+		{name: "not logged private", recipient: contact, opts: TextOptions{QuotedMessageID: "OTHER"}, wantParticipant: contact.String()},
+		{name: "not logged group", recipient: group, opts: TextOptions{QuotedMessageID: "OTHER"}, wantErr: true},
+		{
+			name: "not logged group with participant", recipient: group,
+			opts:            TextOptions{QuotedMessageID: "OTHER", QuotedParticipant: "6283"},
+			wantParticipant: "6283@s.whatsapp.net",
+		},
+		{name: "empty mention", recipient: contact, opts: TextOptions{Mentions: []string{" "}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ch.createTextMessage(sender, tt.recipient, "reply", tt.opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTextOptions) {
+					t.Fatalf("err = %v, want ErrInvalidTextOptions", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createTextMessage: %v", err)
+			}
+
+			contextInfo := msg.GetExtendedTextMessage().GetContextInfo()
+			if contextInfo.GetStanzaId() != tt.opts.QuotedMessageID {
+				t.Fatalf("StanzaId = %q, want %q", contextInfo.GetStanzaId(), tt.opts.QuotedMessageID)
+			}
+			if contextInfo.GetParticipant() != tt.wantParticipant {
+				t.Fatalf("Participant = %q, want %q", contextInfo.GetParticipant(), tt.wantParticipant)
+			}
+			if tt.wantQuotedText == "" {
+				if contextInfo.QuotedMessage != nil {
+					t.Fatalf("QuotedMessage = %v, want none", contextInfo.QuotedMessage)
+				}
+				return
+			}
+			if got := contextInfo.GetQuotedMessage().GetConversation(); got != tt.wantQuotedText {
+				t.Fatalf("quoted text = %q, want %q", got, tt.wantQuotedText)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 var (
 	Conf Config
 	Env  string
@@ -14,6 +16,53 @@ var (
 		"logLevel":   "DEBUG",
 		"logFormat":  "text",
 		"signString": "supersecret",
+
+		"pairing.onMismatch": "reject",
+		"pairing.expiry":     "3m",
+
+		"reconnect.initialBackoff":     "2s",
+		"reconnect.maxBackoff":         "5m",
+		"reconnect.maxAttempts":        0,
+		"reconnect.keepAliveMaxErrors": 3,
+
+		"database.driver":             "sqlite3",
+		"database.dsn":                "file:examplestore.db?_foreign_keys=on",
+		"database.logLevel":           "WARN",
+		"database.maxOpenConns":       0,
+		"database.maxIdleConns":       2,
+		"database.connMaxLifetime":    "0s",
+		"database.sqlite.wal":         true,
+		"database.sqlite.busyTimeout": "5s",
+
+		"webhook.enable":         true,
+		"webhook.url":            "",
+		"webhook.events":         []string{},
+		"webhook.timeout":        "10s",
+		"webhook.maxAttempts":    6,
+		"webhook.initialBackoff": "5s",
+		"webhook.maxBackoff":     "10m",
+		"webhook.workers":        4,
+		"webhook.queueSize":      1000,
+
+		"media.download":  false,
+		"media.dir":       "data/media",
+		"media.publicUrl": "",
+		"media.maxSize":   104857600,
+		"media.workers":   4,
+		"media.timeout":   "2m",
+
+		"autoReply.enable": true,
+		"autoReply.maxAge": "5m",
+
+		"businessHours.enable": true,
+
+		"bot.enable":      false,
+		"bot.prefix":      "/",
+		"bot.callbackUrl": "",
+		"bot.timeout":     "10s",
+
+		"cronjob.cleanupDevices.enable":          true,
+		"cronjob.cleanupDevices.cronJobSchedule": "*/5 * * * *",
 	}
 	configName = map[string]string{
 		"local": "config.local",
@@ -25,13 +74,23 @@ var (
 )
 
 type Config struct {
-	Env            string   `mapstructure:"env"`
-	Port           int      `mapstructure:"port"`
-	StartUp        StartUp  `mapstructure:"startUp"`
-	ShutDown       ShutDown `mapstructure:"shutDown"`
-	AutoLogout     bool     `mapstructure:"autoLogout"`
-	AutoDisconnect bool     `mapstructure:"autoDisconnect"`
-	Cronjob        Cronjob  `mapstructure:"cronjob"`
+	Env            string        `mapstructure:"env"`
+	Port           int           `mapstructure:"port"`
+	SignString     string        `mapstructure:"signString"`
+	StartUp        StartUp       `mapstructure:"startUp"`
+	ShutDown       ShutDown      `mapstructure:"shutDown"`
+	AutoLogout     bool          `mapstructure:"autoLogout"`
+	AutoDisconnect bool          `mapstructure:"autoDisconnect"`
+	Cronjob        Cronjob       `mapstructure:"cronjob"`
+	Pairing        Pairing       `mapstructure:"pairing"`
+	Reconnect      Reconnect     `mapstructure:"reconnect"`
+	Auth           Auth          `mapstructure:"auth"`
+	Database       Database      `mapstructure:"database"`
+	Webhook        Webhook       `mapstructure:"webhook"`
+	Media          Media         `mapstructure:"media"`
+	AutoReply      AutoReply     `mapstructure:"autoReply"`
+	BusinessHours  BusinessHours `mapstructure:"businessHours"`
+	Bot            Bot           `mapstructure:"bot"`
 }
 
 type StartUp struct {
@@ -43,10 +102,120 @@ type ShutDown struct {
 }
 
 type Cronjob struct {
-	AutoPresence AutoPresence `mapstructure:"autoPresence"`
+	AutoPresence   AutoPresence   `mapstructure:"autoPresence"`
+	CleanupDevices CleanupDevices `mapstructure:"cleanupDevices"`
 }
 
 type AutoPresence struct {
 	Enable          bool   `mapstructure:"enable"`
 	CronJobSchedule string `mapstructure:"cronJobSchedule"`
 }
+
+type CleanupDevices struct {
+	Enable          bool   `mapstructure:"enable"`
+	CronJobSchedule string `mapstructure:"cronJobSchedule"`
+}
+
+type Pairing struct {
+	// OnMismatch is the action when the scanned phone is not the requested sender, "reject" or "rekey"
+	OnMismatch string `mapstructure:"onMismatch"`
+	// Expiry is how long an unscanned pairing is kept before the client is disconnected
+	Expiry time.Duration `mapstructure:"expiry"`
+}
+
+type Reconnect struct {
+	InitialBackoff time.Duration `mapstructure:"initialBackoff"`
+	MaxBackoff     time.Duration `mapstructure:"maxBackoff"`
+	// MaxAttempts is the number of connect attempts before giving up, 0 is retrying forever
+	MaxAttempts int `mapstructure:"maxAttempts"`
+	// KeepAliveMaxErrors is the number of keepalive timeouts in a row before the connection is recreated
+	KeepAliveMaxErrors int `mapstructure:"keepAliveMaxErrors"`
+}
+
+type Auth struct {
+	// AdminToken protects the admin endpoints, they are disabled when it is empty
+	AdminToken string `mapstructure:"adminToken"`
+	// StreamToken protects the websocket event stream, the admin token is accepted as well
+	StreamToken string `mapstructure:"streamToken"`
+	// MediaToken protects the downloaded media, the admin token is accepted as well
+	MediaToken string `mapstructure:"mediaToken"`
+}
+
+type Database struct {
+	// Driver is the sql dialect of the whatsmeow store, "sqlite3" or "postgres"
+	Driver string `mapstructure:"driver"`
+	// DSN is the sqlite file uri or the postgres connection string
+	DSN string `mapstructure:"dsn"`
+	// LogLevel is the level of the whatsmeow store logger: DEBUG, INFO, WARN or ERROR
+	LogLevel string `mapstructure:"logLevel"`
+	// MaxOpenConns of the pool, 0 is unlimited
+	MaxOpenConns    int           `mapstructure:"maxOpenConns"`
+	MaxIdleConns    int           `mapstructure:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `mapstructure:"connMaxLifetime"`
+	Sqlite          Sqlite        `mapstructure:"sqlite"`
+}
+
+type Sqlite struct {
+	// WAL enables the write-ahead log so the reads are not blocked by a write
+	WAL bool `mapstructure:"wal"`
+	// BusyTimeout is how long a query waits for a locked database before it fails
+	BusyTimeout time.Duration `mapstructure:"busyTimeout"`
+}
+
+type Webhook struct {
+	Enable bool `mapstructure:"enable"`
+	// URL receives the events of every session that has no webhook of its own
+	URL string `mapstructure:"url"`
+	// Events is the default event filter, every event is sent when it is empty
+	Events  []string      `mapstructure:"events"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxAttempts is the number of deliveries before the event is moved to the dead letters
+	MaxAttempts    int           `mapstructure:"maxAttempts"`
+	InitialBackoff time.Duration `mapstructure:"initialBackoff"`
+	MaxBackoff     time.Duration `mapstructure:"maxBackoff"`
+	Workers        int           `mapstructure:"workers"`
+	QueueSize      int           `mapstructure:"queueSize"`
+}
+
+type Media struct {
+	// Download saves the media of the inbound messages, it is referenced by mediaId on the message events
+	Download bool `mapstructure:"download"`
+	// Dir is the directory of the local media store
+	Dir string `mapstructure:"dir"`
+	// PublicURL is the base url of the mediaUrl on the message events, the url is relative when it is empty
+	PublicURL string `mapstructure:"publicUrl"`
+	// MaxSize in bytes, larger media is not downloaded
+	MaxSize int64         `mapstructure:"maxSize"`
+	Workers int           `mapstructure:"workers"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+type AutoReply struct {
+	// Enable evaluates the auto-reply rules of the sessions on the incoming messages
+	Enable bool `mapstructure:"enable"`
+	// MaxAge skips the messages that are older, e.g. the backlog received after a reconnect,
+	// it applies to the bot commands and the away messages as well
+	MaxAge time.Duration `mapstructure:"maxAge"`
+}
+
+type BusinessHours struct {
+	// Enable sends the away message of the sessions that have business hours outside of them
+	Enable bool `mapstructure:"enable"`
+}
+
+type Bot struct {
+	// Enable answers the inbound texts that start with the prefix as commands
+	Enable bool   `mapstructure:"enable"`
+	Prefix string `mapstructure:"prefix"`
+	// CallbackURL receives the commands that have no handler of their own
+	CallbackURL string        `mapstructure:"callbackUrl"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	// Commands are answered by an http callback each
+	Commands []BotCommand `mapstructure:"commands"`
+}
+
+type BotCommand struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	URL         string `mapstructure:"url"`
+}
@@ -5,17 +5,20 @@ import (
 	"whatsapp_multi_session_general/commandhandler"
 	"whatsapp_multi_session_general/config"
 	"whatsapp_multi_session_general/cronjob/crontab"
+	"whatsapp_multi_session_general/session"
 
 	"go.mau.fi/whatsmeow/types"
 )
 
 type CronJobs struct {
 	CommandHandler commandhandler.CommandHandler
+	Sessions       *session.Registry
 }
 
-func NewCronJobs(commandhandler commandhandler.CommandHandler) *CronJobs {
+func NewCronJobs(commandhandler commandhandler.CommandHandler, sessions *session.Registry) *CronJobs {
 	return &CronJobs{
 		CommandHandler: commandhandler,
+		Sessions:       sessions,
 	}
 }
 
@@ -42,21 +45,37 @@ func (c CronJobs) Run() {
 			crontabInit.Shutdown()
 		}
 	}
+
+	if config.Conf.Cronjob.CleanupDevices.Enable {
+		schedule := config.Conf.Cronjob.CleanupDevices.CronJobSchedule
+		if schedule == "" {
+			schedule = "*/5 * * * *"
+		}
+		err := crontabInit.AddJob(schedule, func() {
+			err := c.CleanupDevices()
+			if err != nil {
+				fmt.Printf("err on job CleanupDevices : %v \n", err)
+				return
+			}
+		})
+		if err != nil {
+			fmt.Printf("err on job CleanupDevices : %v \n", err)
+		}
+	}
 }
 
 func (c CronJobs) AutoPresence() (err error) {
-	devices := commandhandler.Clients
+	devices := c.Sessions.List()
 	if len(devices) > 0 {
 		for _, val := range devices {
-			if val.Store.ID.User != "" {
+			if val.Store.ID != nil && val.Store.ID.User != "" {
 				// send presence
-				if val.Store.ID != nil {
-					err = val.SendPresence(types.PresenceAvailable)
-					if err != nil {
-						fmt.Errorf("err client.SendPresence : %v ", err)
-					}
-					fmt.Println("send presence is success")
+				err = val.SendPresence(types.PresenceAvailable)
+				if err != nil {
+					fmt.Printf("err client.SendPresence : %v \n", err)
+					continue
 				}
+				fmt.Println("send presence is success")
 			} else {
 				continue
 			}
@@ -64,3 +83,13 @@ func (c CronJobs) AutoPresence() (err error) {
 	}
 	return
 }
+
+// CleanupDevices removes the abandoned pairings and the logged-out devices
+func (c CronJobs) CleanupDevices() (err error) {
+	result, err := c.CommandHandler.CleanupDevices()
+	if len(result.ExpiredPairings) > 0 || len(result.OrphanClients) > 0 || len(result.RemovedDevices) > 0 {
+		fmt.Printf("cleanup devices, expired pairings: %v, orphan clients: %v, removed devices: %v \n",
+			result.ExpiredPairings, result.OrphanClients, result.RemovedDevices)
+	}
+	return err
+}
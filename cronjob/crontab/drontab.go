@@ -378,3 +378,61 @@ func getTick(t time.Time) tick {
 		dayOfWeek: int(t.Weekday()),
 	}
 }
+
+// Schedule is a parsed schedule string, it tells whether a minute matches without running a job
+type Schedule struct {
+	job *job
+}
+
+// ParseSchedule parses a schedule string like * * * * *, the syntax is the same as AddJob
+func ParseSchedule(s string) (Schedule, error) {
+	j, err := parseSchedule(s)
+	if err != nil {
+		return Schedule{}, err
+	}
+	return Schedule{job: j}, nil
+}
+
+// Matches returns true when the minute of t is in the schedule, t is evaluated in its own location
+func (s Schedule) Matches(t time.Time) bool {
+	if s.job == nil {
+		return false
+	}
+	return s.job.tick(getTick(t))
+}
+
+// LastOnDay returns the last minute of the day of t that is not after t and is in the schedule,
+// ok is false when the schedule has no such minute on that day. t is evaluated in its own location
+func (s Schedule) LastOnDay(t time.Time) (last time.Time, ok bool) {
+	if s.job == nil {
+		return time.Time{}, false
+	}
+	j := s.job
+	j.RLock()
+	defer j.RUnlock()
+
+	_, day := j.day[t.Day()]
+	_, dayOfWeek := j.dayOfWeek[int(t.Weekday())]
+	if !day && !dayOfWeek {
+		return time.Time{}, false
+	}
+	if _, ok := j.month[int(t.Month())]; !ok {
+		return time.Time{}, false
+	}
+
+	for hour := t.Hour(); hour >= 0; hour-- {
+		if _, ok := j.hour[hour]; !ok {
+			continue
+		}
+		minute := 59
+		if hour == t.Hour() {
+			minute = t.Minute()
+		}
+		for ; minute >= 0; minute-- {
+			if _, ok := j.min[minute]; ok {
+				return time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, t.Location()), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"whatsapp_multi_session_general/config"
+
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+const (
+	DriverSqlite   = "sqlite3"
+	DriverPostgres = "postgres"
+
+	defaultSqliteDSN = "file:examplestore.db?_foreign_keys=on"
+)
+
+var (
+	conn *sqlstore.Container
+	db   *sql.DB
+)
+
+// NewDatabase opens the whatsmeow store configured on the database config section and upgrades its schema
+// together with the tables of the service.
+// the sql connection is returned as well, it is shared with the other tables of the service.
+func NewDatabase() (*sqlstore.Container, *sql.DB, error) {
+	cfg := config.Conf.Database
+
+	driver := strings.ToLower(strings.TrimSpace(cfg.Driver))
+	var dsn string
+	switch driver {
+	case "", "sqlite", DriverSqlite:
+		driver = DriverSqlite
+		dsn = sqliteDSN(cfg)
+	case "postgresql", DriverPostgres:
+		driver = DriverPostgres
+		dsn = cfg.DSN
+		if dsn == "" {
+			return nil, nil, fmt.Errorf("database.dsn should be filled for the %s driver", driver)
+		}
+	default:
+		return nil, nil, fmt.Errorf("unsupported database driver %q, use %s or %s", cfg.Driver, DriverSqlite, DriverPostgres)
+	}
+
+	logLevel := strings.ToUpper(cfg.LogLevel)
+	if logLevel == "" {
+		logLevel = "WARN"
+	}
+	dbLog := waLog.Stdout("Database", logLevel, true)
+
+	// same as sqlstore.New, the sql.DB is opened here so the pool can be configured and shared
+	sqlDB, err := sql.Open(driver, dsn)
+	if err != nil {
+		fmt.Printf("err sql.Open : %v \n", err)
+		return nil, nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	container := sqlstore.NewWithDB(sqlDB, driver, dbLog)
+	err = container.Upgrade()
+	if err != nil {
+		fmt.Printf("err container.Upgrade : %v \n", err)
+		_ = sqlDB.Close()
+		return nil, nil, err
+	}
+
+	err = upgradeApp(sqlDB)
+	if err != nil {
+		fmt.Printf("err upgradeApp : %v \n", err)
+		_ = sqlDB.Close()
+		return nil, nil, err
+	}
+
+	SetConnection(container)
+	SetDB(sqlDB)
+
+	return container, sqlDB, nil
+}
+
+// GetConnection : Get Available Connection
+func GetConnection() *sqlstore.Container {
+	return conn
+}
+
+// SetConnection : Set Available Connection
+func SetConnection(connection *sqlstore.Container) {
+	conn = connection
+}
+
+// GetDB : Get the underlying sql connection of the sqlstore
+func GetDB() *sql.DB {
+	return db
+}
+
+// SetDB : Set the underlying sql connection of the sqlstore
+func SetDB(sqlDB *sql.DB) {
+	db = sqlDB
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are the tables of the service itself, next to the whatsmeow tables on the same database.
+// the statements must work on both sqlite and postgres, a migration is never changed once it is released,
+// a new one is appended instead.
+var migrations = []string{
+	// 1: outbound message log
+	`CREATE TABLE IF NOT EXISTS wa_outbound_messages (
+		sender       TEXT   NOT NULL,
+		message_id   TEXT   NOT NULL,
+		recipient    TEXT   NOT NULL,
+		type         TEXT   NOT NULL,
+		body         TEXT   NOT NULL DEFAULT '',
+		file_name    TEXT   NOT NULL DEFAULT '',
+		status       TEXT   NOT NULL,
+		error        TEXT   NOT NULL DEFAULT '',
+		created_at   BIGINT NOT NULL,
+		updated_at   BIGINT NOT NULL,
+		sent_at      BIGINT,
+		delivered_at BIGINT,
+		read_at      BIGINT,
+		played_at    BIGINT,
+		failed_at    BIGINT,
+		PRIMARY KEY (sender, message_id)
+	)`,
+	// 2: receipts of every participant of an outbound group message
+	`CREATE TABLE IF NOT EXISTS wa_outbound_receipts (
+		sender       TEXT   NOT NULL,
+		message_id   TEXT   NOT NULL,
+		participant  TEXT   NOT NULL,
+		delivered_at BIGINT,
+		read_at      BIGINT,
+		played_at    BIGINT,
+		updated_at   BIGINT NOT NULL,
+		PRIMARY KEY (sender, message_id, participant)
+	)`,
+	// 3-5: inbound messages of every session and the timeline indexes
+	`CREATE TABLE IF NOT EXISTS wa_inbound_messages (
+		session        TEXT    NOT NULL,
+		chat           TEXT    NOT NULL,
+		message_id     TEXT    NOT NULL,
+		sender         TEXT    NOT NULL,
+		push_name      TEXT    NOT NULL DEFAULT '',
+		is_group       BOOLEAN NOT NULL DEFAULT FALSE,
+		type           TEXT    NOT NULL,
+		text           TEXT    NOT NULL DEFAULT '',
+		mime_type      TEXT    NOT NULL DEFAULT '',
+		file_name      TEXT    NOT NULL DEFAULT '',
+		quoted_id      TEXT    NOT NULL DEFAULT '',
+		quoted_sender  TEXT    NOT NULL DEFAULT '',
+		timestamp      BIGINT  NOT NULL,
+		created_at     BIGINT  NOT NULL,
+		PRIMARY KEY (session, chat, message_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS wa_inbound_messages_timeline ON wa_inbound_messages (session, chat, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS wa_outbound_messages_timeline ON wa_outbound_messages (sender, recipient, created_at)`,
+	// 6-7: webhook of every session and the events that could not be delivered
+	`CREATE TABLE IF NOT EXISTS wa_webhooks (
+		session    TEXT    NOT NULL PRIMARY KEY,
+		url        TEXT    NOT NULL,
+		events     TEXT    NOT NULL DEFAULT '',
+		enabled    BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at BIGINT  NOT NULL,
+		updated_at BIGINT  NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wa_webhook_dead_letters (
+		event_id    TEXT    NOT NULL PRIMARY KEY,
+		session     TEXT    NOT NULL,
+		url         TEXT    NOT NULL,
+		event       TEXT    NOT NULL,
+		payload     TEXT    NOT NULL,
+		attempts    INTEGER NOT NULL,
+		last_status INTEGER NOT NULL DEFAULT 0,
+		last_error  TEXT    NOT NULL DEFAULT '',
+		created_at  BIGINT  NOT NULL,
+		failed_at   BIGINT  NOT NULL
+	)`,
+	// 8-9: media of the inbound messages, the content itself is on the media store
+	`CREATE TABLE IF NOT EXISTS wa_media (
+		id         TEXT   NOT NULL PRIMARY KEY,
+		session    TEXT   NOT NULL,
+		chat       TEXT   NOT NULL,
+		message_id TEXT   NOT NULL,
+		type       TEXT   NOT NULL,
+		mime_type  TEXT   NOT NULL DEFAULT '',
+		file_name  TEXT   NOT NULL DEFAULT '',
+		size       BIGINT NOT NULL DEFAULT 0,
+		status     TEXT   NOT NULL,
+		error      TEXT   NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL
+	)`,
+	`ALTER TABLE wa_inbound_messages ADD COLUMN media_id TEXT NOT NULL DEFAULT ''`,
+	// 10-12: keyword auto-reply rules and the last reply of every rule to a contact for the cooldown
+	`CREATE TABLE IF NOT EXISTS wa_auto_replies (
+		id              TEXT    NOT NULL PRIMARY KEY,
+		session         TEXT    NOT NULL,
+		name            TEXT    NOT NULL DEFAULT '',
+		match_type      TEXT    NOT NULL,
+		pattern         TEXT    NOT NULL,
+		case_sensitive  BOOLEAN NOT NULL DEFAULT FALSE,
+		reply_text      TEXT    NOT NULL DEFAULT '',
+		reply_media_url TEXT    NOT NULL DEFAULT '',
+		in_groups       BOOLEAN NOT NULL DEFAULT FALSE,
+		start_time      TEXT    NOT NULL DEFAULT '',
+		end_time        TEXT    NOT NULL DEFAULT '',
+		timezone        TEXT    NOT NULL DEFAULT '',
+		cooldown        INTEGER NOT NULL DEFAULT 0,
+		priority        INTEGER NOT NULL DEFAULT 0,
+		enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at      BIGINT  NOT NULL,
+		updated_at      BIGINT  NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS wa_auto_replies_session ON wa_auto_replies (session, priority)`,
+	`CREATE TABLE IF NOT EXISTS wa_auto_reply_hits (
+		rule_id    TEXT   NOT NULL,
+		contact    TEXT   NOT NULL,
+		replied_at BIGINT NOT NULL,
+		PRIMARY KEY (rule_id, contact)
+	)`,
+	// 13-14: business hours of the sessions and the closed window of the last away message to every contact
+	`CREATE TABLE IF NOT EXISTS wa_business_hours (
+		session      TEXT    NOT NULL PRIMARY KEY,
+		timezone     TEXT    NOT NULL DEFAULT '',
+		hours        TEXT    NOT NULL,
+		holidays     TEXT    NOT NULL DEFAULT '',
+		away_message TEXT    NOT NULL,
+		enabled      BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at   BIGINT  NOT NULL,
+		updated_at   BIGINT  NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wa_away_replies (
+		session      TEXT   NOT NULL,
+		contact      TEXT   NOT NULL,
+		window_start BIGINT NOT NULL,
+		sent_at      BIGINT NOT NULL,
+		PRIMARY KEY (session, contact)
+	)`,
+	// 15: contacts that stopped the automatic replies of a session with the stop command
+	`CREATE TABLE IF NOT EXISTS wa_bot_opt_outs (
+		session    TEXT   NOT NULL,
+		contact    TEXT   NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (session, contact)
+	)`,
+	// 16-17: call settings of the sessions and the received calls
+	`CREATE TABLE IF NOT EXISTS wa_call_settings (
+		session    TEXT    NOT NULL PRIMARY KEY,
+		reject     BOOLEAN NOT NULL,
+		reply      TEXT    NOT NULL DEFAULT '',
+		created_at BIGINT  NOT NULL,
+		updated_at BIGINT  NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS wa_calls (
+		session      TEXT    NOT NULL,
+		call_id      TEXT    NOT NULL,
+		caller       TEXT    NOT NULL,
+		call_creator TEXT    NOT NULL DEFAULT '',
+		is_video     BOOLEAN NOT NULL DEFAULT FALSE,
+		is_group     BOOLEAN NOT NULL DEFAULT FALSE,
+		platform     TEXT    NOT NULL DEFAULT '',
+		rejected     BOOLEAN NOT NULL DEFAULT FALSE,
+		reject_error TEXT    NOT NULL DEFAULT '',
+		replied      BOOLEAN NOT NULL DEFAULT FALSE,
+		timestamp    BIGINT  NOT NULL,
+		created_at   BIGINT  NOT NULL,
+		PRIMARY KEY (session, call_id)
+	)`,
+	// 18-19: edited and revoked (deleted for everyone) outbound messages
+	`ALTER TABLE wa_outbound_messages ADD COLUMN edited_at BIGINT`,
+	`ALTER TABLE wa_outbound_messages ADD COLUMN revoked_at BIGINT`,
+	// 20-21: the message of a media and the number of its downloads, a failed download is retried from it
+	`ALTER TABLE wa_media ADD COLUMN message TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE wa_media ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1`,
+}
+
+// upgradeApp runs the migrations that are not applied yet, the applied version is kept on wa_schema_version.
+func upgradeApp(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS wa_schema_version (version INTEGER NOT NULL)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM wa_schema_version`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(migrations[i]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err = tx.Exec(`DELETE FROM wa_schema_version`); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if _, err = tx.Exec(`INSERT INTO wa_schema_version (version) VALUES ($1)`, i+1); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,6 @@
+package database
+
+import (
+	// postgres driver of the whatsmeow store, selected with database.driver: postgres
+	_ "github.com/lib/pq"
+)
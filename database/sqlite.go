@@ -1,34 +1,43 @@
 package database
 
 import (
-	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 
-	waLog "go.mau.fi/whatsmeow/util/log"
+	"whatsapp_multi_session_general/config"
 
-	"go.mau.fi/whatsmeow/store/sqlstore"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-var conn *sqlstore.Container
+// sqliteDSN adds the pragmas of the sqlite config to the dsn, a pragma that is already in the dsn is kept as it is.
+func sqliteDSN(cfg config.Database) string {
+	dsn := cfg.DSN
+	if dsn == "" {
+		dsn = defaultSqliteDSN
+	}
 
-func NewSqlite() (*sqlstore.Container, error) {
-	dbLog := waLog.Stdout("Database", "DEBUG", true)
-	container, err := sqlstore.New("sqlite3", "file:examplestore.db?_foreign_keys=on", dbLog)
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	query, err := url.ParseQuery(rawQuery)
 	if err != nil {
-		fmt.Errorf("err sqlstore.New : %v ", err)
-		return nil, err
+		// not parseable, the dsn is used as it is configured
+		return dsn
 	}
 
-	SetConnection(container)
-
-	return container, nil
-}
+	// whatsmeow relies on the foreign keys to delete the rows of a device
+	setDefault(query, "_foreign_keys", "on")
+	if cfg.Sqlite.WAL {
+		setDefault(query, "_journal_mode", "WAL")
+	}
+	if cfg.Sqlite.BusyTimeout > 0 {
+		setDefault(query, "_busy_timeout", strconv.FormatInt(cfg.Sqlite.BusyTimeout.Milliseconds(), 10))
+	}
 
-// GetConnection : Get Available Connection
-func GetConnection() *sqlstore.Container {
-	return conn
+	return path + "?" + query.Encode()
 }
 
-// SetConnection : Set Available Connection
-func SetConnection(connection *sqlstore.Container) {
-	conn = connection
+func setDefault(query url.Values, key, value string) {
+	if query.Get(key) == "" {
+		query.Set(key, value)
+	}
 }
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+type autoReplyRequest struct {
+	Name          string `json:"name"`
+	MatchType     string `json:"matchType" binding:"required"`
+	Pattern       string `json:"pattern" binding:"required"`
+	CaseSensitive bool   `json:"caseSensitive"`
+	ReplyText     string `json:"replyText"`
+	ReplyMediaURL string `json:"replyMediaUrl"`
+	Groups        bool   `json:"groups"`
+	StartTime     string `json:"startTime"`
+	EndTime       string `json:"endTime"`
+	Timezone      string `json:"timezone"`
+	Cooldown      int    `json:"cooldown"`
+	Priority      int    `json:"priority"`
+	Enabled       *bool  `json:"enabled"`
+}
+
+func (r autoReplyRequest) rule() repository.AutoReply {
+	return repository.AutoReply{
+		Name:          r.Name,
+		MatchType:     r.MatchType,
+		Pattern:       r.Pattern,
+		CaseSensitive: r.CaseSensitive,
+		ReplyText:     r.ReplyText,
+		ReplyMediaURL: r.ReplyMediaURL,
+		Groups:        r.Groups,
+		StartTime:     r.StartTime,
+		EndTime:       r.EndTime,
+		Timezone:      r.Timezone,
+		Cooldown:      r.Cooldown,
+		Priority:      r.Priority,
+		Enabled:       r.Enabled == nil || *r.Enabled,
+	}
+}
+
+// ServeAutoReplies returns the auto-reply rules of the sender in the order they are evaluated
+func (h Handler) ServeAutoReplies(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	response, err := h.CommandHandler.ListAutoReplies(senderJidTypes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// ServeAutoReply returns an auto-reply rule of the sender
+func (h Handler) ServeAutoReply(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	response, err := h.CommandHandler.GetAutoReply(senderJidTypes, c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "auto reply tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// CreateAutoReply adds an auto-reply rule to the sender
+func (h Handler) CreateAutoReply(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	var reqBody autoReplyRequest
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	response, err := h.CommandHandler.CreateAutoReply(senderJidTypes, reqBody.rule())
+	if errors.Is(err, commandhandler.ErrInvalidAutoReply) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "success", "result": response})
+}
+
+// UpdateAutoReply replaces an auto-reply rule of the sender
+func (h Handler) UpdateAutoReply(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	var reqBody autoReplyRequest
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	response, err := h.CommandHandler.UpdateAutoReply(senderJidTypes, c.Param("id"), reqBody.rule())
+	switch {
+	case errors.Is(err, commandhandler.ErrInvalidAutoReply):
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "auto reply tidak ditemukan"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// DeleteAutoReply removes an auto-reply rule of the sender
+func (h Handler) DeleteAutoReply(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	err := h.CommandHandler.DeleteAutoReply(senderJidTypes, c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "auto reply tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success delete"})
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ServeBusinessHours returns the business hours of the sender
+func (h Handler) ServeBusinessHours(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	response, err := h.CommandHandler.GetBusinessHours(senderJidTypes)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "jam operasional tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// PutBusinessHours sets the business hours and the away message of the sender
+func (h Handler) PutBusinessHours(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	var reqBody struct {
+		Timezone    string   `json:"timezone"`
+		Hours       []string `json:"hours" binding:"required"`
+		Holidays    []string `json:"holidays"`
+		AwayMessage string   `json:"awayMessage" binding:"required"`
+		Enabled     *bool    `json:"enabled"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	hours := repository.BusinessHours{
+		Timezone:    reqBody.Timezone,
+		Hours:       reqBody.Hours,
+		Holidays:    reqBody.Holidays,
+		AwayMessage: reqBody.AwayMessage,
+		Enabled:     reqBody.Enabled == nil || *reqBody.Enabled,
+	}
+	response, err := h.CommandHandler.PutBusinessHours(senderJidTypes, hours)
+	if errors.Is(err, commandhandler.ErrInvalidBusinessHours) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// DeleteBusinessHours removes the business hours of the sender, the away message is not sent anymore
+func (h Handler) DeleteBusinessHours(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	err := h.CommandHandler.DeleteBusinessHours(senderJidTypes)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "jam operasional tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success delete"})
+}
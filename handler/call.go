@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"whatsapp_multi_session_general/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ServeCallSettings returns the call settings of the sender
+func (h Handler) ServeCallSettings(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	response, err := h.CommandHandler.GetCallSettings(senderJidTypes)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "pengaturan panggilan tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// PutCallSettings sets whether the incoming calls of the sender are rejected and the reply to the caller
+func (h Handler) PutCallSettings(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	var reqBody struct {
+		Reject *bool  `json:"reject" binding:"required"`
+		Reply  string `json:"reply"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	settings := repository.CallSettings{Reject: *reqBody.Reject, Reply: reqBody.Reply}
+	response, err := h.CommandHandler.PutCallSettings(senderJidTypes, settings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// DeleteCallSettings removes the call settings of the sender, the calls are not rejected anymore
+func (h Handler) DeleteCallSettings(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	err := h.CommandHandler.DeleteCallSettings(senderJidTypes)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "pengaturan panggilan tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success delete"})
+}
+
+// ServeCalls returns the calls received by the sender, newest first
+func (h Handler) ServeCalls(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	response, err := h.CommandHandler.ListCalls(senderJidTypes, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
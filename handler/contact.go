@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+type contactRequest struct {
+	Name         string `json:"name"`
+	Organization string `json:"organization"`
+	Phones       []struct {
+		Number string `json:"number"`
+		// Type is CELL, WORK, HOME or MAIN, it is CELL when empty
+		Type string `json:"type"`
+	} `json:"phones"`
+	Emails []string `json:"emails"`
+}
+
+// ServeSendContact sends contact cards to the recipients, the contacts are rendered as vCard 3.0
+func (h Handler) ServeSendContact(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
+		return
+	}
+	if !clientSpecificUser.IsLoggedIn() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode"})
+		return
+	}
+
+	var reqBody struct {
+		Recipients []string         `json:"recipients" binding:"required"`
+		Contacts   []contactRequest `json:"contacts" binding:"required"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	contacts := make([]commandhandler.Contact, 0, len(reqBody.Contacts))
+	for _, req := range reqBody.Contacts {
+		contact := commandhandler.Contact{Name: req.Name, Organization: req.Organization, Emails: req.Emails}
+		for _, phone := range req.Phones {
+			contact.Phones = append(contact.Phones, commandhandler.ContactPhone{Number: phone.Number, Type: phone.Type})
+		}
+		contacts = append(contacts, contact)
+	}
+
+	response, err := h.CommandHandler.NewHandleSendContacts(senderJidTypes, reqBody.Recipients, contacts)
+	if errors.Is(err, commandhandler.ErrInvalidContact) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ServeEditMessage replaces the text of a text message the sender sent to the chat
+func (h Handler) ServeEditMessage(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
+		return
+	}
+	if !clientSpecificUser.IsLoggedIn() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode"})
+		return
+	}
+
+	var reqBody struct {
+		Chat    string `json:"chat" binding:"required"`
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	msgID, err := h.CommandHandler.HandleEditMessage(senderJidTypes, reqBody.Chat, c.Param("id"), reqBody.Message)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "pesan tidak ditemukan"})
+		return
+	}
+	if errors.Is(err, commandhandler.ErrInvalidEdit) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "id_pesan": msgID})
+}
+
+// RevokeMessage deletes a message of the chat for everyone, the chat is the chat query parameter
+func (h Handler) RevokeMessage(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	chat := c.Query("chat")
+	if chat == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "chat seharusnya diisi dengan nomor atau jid yang valid"})
+		return
+	}
+
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
+		return
+	}
+	if !clientSpecificUser.IsLoggedIn() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode"})
+		return
+	}
+
+	msgID, err := h.CommandHandler.HandleRevokeMessage(senderJidTypes, chat, c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "pesan tidak ditemukan"})
+		return
+	}
+	if errors.Is(err, commandhandler.ErrInvalidRevoke) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success delete", "id_pesan": msgID})
+}
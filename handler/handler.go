@@ -8,16 +8,36 @@ import (
 	"go.mau.fi/whatsmeow/types"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"whatsapp_multi_session_general/backup"
 	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/repository"
+	"whatsapp_multi_session_general/session"
+	"whatsapp_multi_session_general/stream"
+	"whatsapp_multi_session_general/webhook"
+)
+
+const (
+	// maxMessageStatusBatch is the maximum ids of a single message status request
+	maxMessageStatusBatch = 100
 )
 
 type Handler struct {
 	CommandHandler commandhandler.CommandHandler
+	Sessions       *session.Registry
+	Webhooks       *webhook.Dispatcher
+	Stream         *stream.Hub
 }
 
-func NewHandler(commandhandler commandhandler.CommandHandler) Handler {
+func NewHandler(commandhandler commandhandler.CommandHandler, sessions *session.Registry, webhooks *webhook.Dispatcher, hub *stream.Hub) Handler {
 	return Handler{
 		CommandHandler: commandhandler,
+		Sessions:       sessions,
+		Webhooks:       webhooks,
+		Stream:         hub,
 	}
 }
 
@@ -32,8 +52,8 @@ func (h Handler) ServeSendPresence(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
 		return
 	}
@@ -63,8 +83,8 @@ func (h Handler) ServeSendText(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
 		return
 	}
@@ -73,6 +93,10 @@ func (h Handler) ServeSendText(c *gin.Context) {
 		var msgBody struct {
 			Recipient string `json:"recipient" binding:"required"`
 			Message   string `json:"message" binding:"required"`
+			// QuotedMessageID replies to a message of the chat, Mentions are the mentioned numbers or jids
+			QuotedMessageID   string   `json:"quotedMessageId"`
+			QuotedParticipant string   `json:"quotedParticipant"`
+			Mentions          []string `json:"mentions"`
 		}
 
 		if err := c.BindJSON(&msgBody); err != nil {
@@ -80,7 +104,16 @@ func (h Handler) ServeSendText(c *gin.Context) {
 			return
 		}
 
-		msgID, err := h.CommandHandler.HandleSendNewTextMessage(senderJidTypes, msgBody.Message, msgBody.Recipient)
+		opts := commandhandler.TextOptions{
+			QuotedMessageID:   msgBody.QuotedMessageID,
+			QuotedParticipant: msgBody.QuotedParticipant,
+			Mentions:          msgBody.Mentions,
+		}
+		msgID, err := h.CommandHandler.HandleSendTextMessage(senderJidTypes, msgBody.Message, msgBody.Recipient, opts)
+		if errors.Is(err, commandhandler.ErrInvalidTextOptions) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 			return
@@ -108,8 +141,8 @@ func (h Handler) ServeSendTextBulk(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
 		return
 	}
@@ -149,27 +182,43 @@ func (h Handler) ServeStatus(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	_, isTracked := h.Sessions.Status(senderJidTypes.User)
+	status := h.CommandHandler.SessionStatus(senderJidTypes.User)
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok && !isTracked {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
 		return
 	}
 
-	if clientSpecificUser.IsLoggedIn() {
+	if ok && clientSpecificUser.IsLoggedIn() {
 		response := struct {
-			ID       string `json:"id"`
-			PushName string `json:"pushName"`
-			IsLogin  bool   `json:"isLogin"`
+			ID        string               `json:"id"`
+			PushName  string               `json:"pushName"`
+			IsLogin   bool                 `json:"isLogin"`
+			State     session.State        `json:"state"`
+			LastError string               `json:"lastError,omitempty"`
+			UpdatedAt time.Time            `json:"updatedAt"`
+			History   []session.Transition `json:"history"`
 		}{
-			ID:       commandhandler.Clients[senderJidTypes.User].Store.ID.String(),
-			PushName: commandhandler.Clients[senderJidTypes.User].Store.PushName,
-			IsLogin:  commandhandler.Clients[senderJidTypes.User].IsLoggedIn(),
+			ID:        clientSpecificUser.Store.ID.String(),
+			PushName:  clientSpecificUser.Store.PushName,
+			IsLogin:   clientSpecificUser.IsLoggedIn(),
+			State:     status.State,
+			LastError: status.LastError,
+			UpdatedAt: status.UpdatedAt,
+			History:   status.History,
 		}
 
 		c.JSON(http.StatusOK, response)
 		return
 	}
-	c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode"})
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"message":   "gagal kirim, tolong hit endpoint untuk melakukan qrcode",
+		"state":     status.State,
+		"lastError": status.LastError,
+		"updatedAt": status.UpdatedAt,
+		"history":   status.History,
+	})
 }
 
 // ServeAllDevices checks user status
@@ -223,8 +272,8 @@ func (h Handler) ServeCheckUser(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
 		return
 	}
@@ -256,8 +305,8 @@ func (h Handler) NewUploadHandler(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -306,13 +355,13 @@ func (h Handler) NewUploadHandler(c *gin.Context) {
 			var uploadResp []commandhandler.Message
 			mimeType := http.DetectContentType(data)
 			if isImage(mimeType) {
-				uploadResp, err = commandhandler.NewHandleSendImage(senderJidTypes, sliceJID, data, captionMsg)
+				uploadResp, err = h.CommandHandler.NewHandleSendImage(senderJidTypes, sliceJID, data, captionMsg)
 			} else if isVideo(mimeType) {
-				uploadResp, err = commandhandler.NewHandleSendVideo(senderJidTypes, sliceJID, data, captionMsg)
+				uploadResp, err = h.CommandHandler.NewHandleSendVideo(senderJidTypes, sliceJID, data, captionMsg)
 			} else if isAudio(mimeType) {
-				uploadResp, err = commandhandler.NewHandleSendAudio(senderJidTypes, sliceJID, data)
+				uploadResp, err = h.CommandHandler.NewHandleSendAudio(senderJidTypes, sliceJID, data)
 			} else {
-				uploadResp, err = commandhandler.NewHandleSendDocument(senderJidTypes, sliceJID, handler.Filename, data, captionMsg)
+				uploadResp, err = h.CommandHandler.NewHandleSendDocument(senderJidTypes, sliceJID, handler.Filename, data, captionMsg)
 			}
 			if err != nil {
 				handleError(c.Writer, http.StatusInternalServerError, "Failed to handle file upload", err)
@@ -429,7 +478,7 @@ func (h Handler) HandleQR(c *gin.Context) {
 
 	if len(devices) > 0 {
 		// Get specific QR code
-		base64qrcode, err := h.CommandHandler.GetSpecificQR(context.Background(), commandhandler.Clients, senderJidTypes)
+		base64qrcode, err := h.CommandHandler.GetSpecificQR(context.Background(), senderJidTypes)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 			return
@@ -445,7 +494,7 @@ func (h Handler) HandleQR(c *gin.Context) {
 
 	} else {
 		// Get specific QR code
-		base64qrcode, err := h.CommandHandler.GetSingleQR(context.Background(), commandhandler.Clients, senderJidTypes)
+		base64qrcode, err := h.CommandHandler.GetSingleQR(context.Background(), senderJidTypes)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 			return
@@ -460,6 +509,70 @@ func (h Handler) HandleQR(c *gin.Context) {
 	}
 }
 
+// HandleQRStream streams every qr code of the pairing as server sent events,
+// followed by a final success, timeout or error event
+func (h Handler) HandleQRStream(c *gin.Context) {
+	// Get query parameters
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "sender should be filled"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	pairing, err := h.CommandHandler.StartPairing(context.Background(), senderJidTypes)
+	if errors.Is(err, commandhandler.ErrAlreadyLogin) {
+		c.SSEvent(primitive.PairingEventSuccess, primitive.PairingEvent{Event: primitive.PairingEventSuccess})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case evt, ok := <-pairing.Events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(evt.Event, evt)
+			return evt.Event == primitive.PairingEventCode
+		case <-c.Request.Context().Done():
+			// the pairing keeps running until it is finished, the events are just not streamed anymore
+			go func() {
+				for range pairing.Events {
+				}
+			}()
+			return false
+		}
+	})
+}
+
+// HandlePairCode starts a session for the sender and returns the pairing code as an alternative to the qr code
+func (h Handler) HandlePairCode(c *gin.Context) {
+	// Get query parameters
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"message": "sender should be filled"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	pairCode, err := h.CommandHandler.GetPairCode(context.Background(), senderJidTypes)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if pairCode == "" {
+		err = errors.New("you are already login")
+		c.JSON(http.StatusOK, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "pair_code": pairCode})
+}
+
 // Logout checks user status
 func (h Handler) Logout(c *gin.Context) {
 	if c.Request.Method == "OPTIONS" {
@@ -475,8 +588,8 @@ func (h Handler) Logout(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
 		return
 	}
@@ -486,10 +599,34 @@ func (h Handler) Logout(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
+	h.Sessions.CompareAndDelete(senderJidTypes.User, clientSpecificUser)
+	h.Sessions.SetState(senderJidTypes.User, session.StateLoggedOut, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "success logout"})
 }
 
+// DeleteDevice fully removes the session of the jid, including the device on the store
+func (h Handler) DeleteDevice(c *gin.Context) {
+	jidStringReq := c.Param("jid")
+	jid, ok := commandhandler.ParseJID(jidStringReq)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid jid request"})
+		return
+	}
+
+	response, err := h.CommandHandler.DeleteSession(jid)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "your request jid is not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "result": response})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success delete", "result": response})
+}
+
 // ServeCheckUserSingle checks user status
 func (h Handler) ServeCheckUserSingle(c *gin.Context) {
 	if c.Request.Method == "OPTIONS" {
@@ -505,8 +642,8 @@ func (h Handler) ServeCheckUserSingle(c *gin.Context) {
 	}
 	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
 
-	clientSpecificUser := commandhandler.Clients[senderJidTypes.User]
-	if clientSpecificUser == nil {
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
 		return
 	}
@@ -538,3 +675,187 @@ func (h Handler) ServeCheckUserSingle(c *gin.Context) {
 	}
 	c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode kembali"})
 }
+
+// ServeBackup exports the keys of the requested senders (every session when empty) into an encrypted archive
+func (h Handler) ServeBackup(c *gin.Context) {
+	var reqBody struct {
+		Passphrase string   `json:"passphrase" binding:"required"`
+		Senders    []string `json:"senders"`
+	}
+
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Error decoding JSON"})
+		return
+	}
+
+	data, exported, err := h.CommandHandler.BackupSessions(reqBody.Senders, reqBody.Passphrase)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	fileName := fmt.Sprintf("wa-sessions-%s.wabackup", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Header("X-Exported-Sessions", strings.Join(exported, ","))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// ServeRestore imports an archive created by ServeBackup and connects the restored sessions
+func (h Handler) ServeRestore(c *gin.Context) {
+	// the upload is bounded like the archive it holds, the rest of the form is a passphrase
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, backup.MaxFileSize+1<<20)
+	var tooLarge *http.MaxBytesError
+	if err := c.Request.ParseMultipartForm(32 << 20); errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": fmt.Sprintf("backup file should be at most %d MB", backup.MaxFileSize>>20)})
+		return
+	}
+
+	passphrase := c.Request.FormValue("passphrase")
+	if passphrase == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "passphrase should be filled"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "No files found in the request"})
+		return
+	}
+	if fileHeader.Size > backup.MaxFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"message": fmt.Sprintf("backup file should be at most %d MB", backup.MaxFileSize>>20)})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to open file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to read file data"})
+		return
+	}
+
+	restored, err := h.CommandHandler.RestoreSessions(data, passphrase, true)
+	if errors.Is(err, backup.ErrWrongPassphrase) || errors.Is(err, backup.ErrUnknownVersion) || errors.Is(err, backup.ErrInvalidBackup) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error(), "restored": restored})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success restore", "restored": restored})
+}
+
+// ServeMessageStatus returns the delivery status of a message sent through the api
+func (h Handler) ServeMessageStatus(c *gin.Context) {
+	messageID := c.Param("id")
+	senderString := c.Query("sender")
+
+	response, err := h.CommandHandler.MessageStatus(senderString, messageID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "pesan tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// ServeMessageStatuses returns the delivery status of multiple messages, the ids are comma separated
+func (h Handler) ServeMessageStatuses(c *gin.Context) {
+	idsString := c.Query("ids")
+	senderString := c.Query("sender")
+
+	if strings.TrimSpace(idsString) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "ids seharusnya diisi dengan id pesan yang dipisahkan koma"})
+		return
+	}
+	messageIDs, _ := commandhandler.ValidateStringArrayAsStringArray(idsString)
+	if len(messageIDs) > maxMessageStatusBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("maximum %d ids per request", maxMessageStatusBatch)})
+		return
+	}
+
+	response, notFound, err := h.CommandHandler.MessageStatuses(senderString, messageIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response, "notFound": notFound})
+}
+
+// ServeChats returns the chats of the sender ordered by the last activity
+func (h Handler) ServeChats(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	response, err := h.CommandHandler.ListChats(senderJidTypes, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// ServeChatMessages returns the timeline of a chat, newest first,
+// the next page is requested with the nextBefore and the nextBeforeId of the response
+func (h Handler) ServeChatMessages(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	chat, ok := commandhandler.ParseJID(c.Param("chat"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid chat request"})
+		return
+	}
+
+	var before time.Time
+	if beforeString := c.Query("before"); beforeString != "" {
+		beforeMillis, err := strconv.ParseInt(beforeString, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "before seharusnya diisi dengan unix timestamp dalam milidetik"})
+			return
+		}
+		before = time.UnixMilli(beforeMillis)
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	response, err := h.CommandHandler.ChatTimeline(senderJidTypes, chat, before, c.Query("beforeId"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	result := gin.H{"message": "success", "result": response}
+	if len(response) > 0 {
+		last := response[len(response)-1]
+		result["nextBefore"] = last.Timestamp.UnixMilli()
+		result["nextBeforeId"] = last.MessageID
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ServeSendLocation sends a location, a venue or a live location to the recipients
+func (h Handler) ServeSendLocation(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
+		return
+	}
+	if !clientSpecificUser.IsLoggedIn() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode"})
+		return
+	}
+
+	var reqBody struct {
+		Recipients []string `json:"recipients" binding:"required"`
+		Latitude   *float64 `json:"latitude" binding:"required"`
+		Longitude  *float64 `json:"longitude" binding:"required"`
+		// Name and Address make the location a venue, URL is the website of the venue
+		Name    string `json:"name"`
+		Address string `json:"address"`
+		URL     string `json:"url"`
+		// Thumbnail is the base64 of the JPEG preview
+		Thumbnail        []byte `json:"thumbnail"`
+		Live             bool   `json:"live"`
+		Caption          string `json:"caption"`
+		SequenceNumber   int64  `json:"sequenceNumber"`
+		AccuracyInMeters uint32 `json:"accuracyInMeters"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	loc := commandhandler.Location{
+		Latitude:         *reqBody.Latitude,
+		Longitude:        *reqBody.Longitude,
+		Name:             reqBody.Name,
+		Address:          reqBody.Address,
+		URL:              reqBody.URL,
+		Thumbnail:        reqBody.Thumbnail,
+		Live:             reqBody.Live,
+		Caption:          reqBody.Caption,
+		SequenceNumber:   reqBody.SequenceNumber,
+		AccuracyInMeters: reqBody.AccuracyInMeters,
+	}
+	response, err := h.CommandHandler.NewHandleSendLocation(senderJidTypes, reqBody.Recipients, loc)
+	if errors.Is(err, commandhandler.ErrInvalidLocation) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeMedia returns the content of a downloaded media of an inbound message
+func (h Handler) ServeMedia(c *gin.Context) {
+	mediaID := c.Param("id")
+
+	file, content, err := h.CommandHandler.OpenMedia(c.Request.Context(), mediaID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"message": "media tidak ditemukan"})
+		return
+	case errors.Is(err, commandhandler.ErrMediaPending):
+		c.JSON(http.StatusAccepted, gin.H{"message": "media sedang diunduh, coba lagi nanti", "result": file})
+		return
+	case errors.Is(err, commandhandler.ErrMediaFailed):
+		c.JSON(http.StatusBadGateway, gin.H{"message": err.Error(), "result": file})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	fileName := file.FileName
+	if fileName == "" {
+		fileName = file.ID
+		if extensions, _ := mime.ExtensionsByType(mimeType); len(extensions) > 0 {
+			fileName += extensions[0]
+		}
+	}
+
+	c.DataFromReader(http.StatusOK, file.Size, mimeType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("inline", map[string]string{"filename": fileName}),
+		"Cache-Control":       fmt.Sprintf("private, max-age=%d", 86400),
+	})
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"whatsapp_multi_session_general/commandhandler"
+
+	"github.com/gin-gonic/gin"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ServeSendReaction reacts to a message of a chat, an empty reaction removes the reaction of the sender
+func (h Handler) ServeSendReaction(c *gin.Context) {
+	senderString := c.Query("sender")
+	if senderString == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "sender seharusnya diisi dengan nomor yang valid"})
+		return
+	}
+	senderJidTypes := types.NewJID(senderString, types.DefaultUserServer)
+
+	clientSpecificUser, ok := h.Sessions.Get(senderJidTypes.User)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "gagal kirim"})
+		return
+	}
+	if !clientSpecificUser.IsLoggedIn() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"message": "gagal kirim, tolong hit endpoint untuk melakukan qrcode"})
+		return
+	}
+
+	var reqBody struct {
+		Chat      string `json:"chat" binding:"required"`
+		MessageID string `json:"messageId" binding:"required"`
+		// Participant is the sender of the message, it is needed for a message of a group that is not logged
+		Participant string `json:"participant"`
+		Reaction    string `json:"reaction"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	msgID, err := h.CommandHandler.HandleSendReaction(senderJidTypes, reqBody.Chat, reqBody.MessageID, reqBody.Participant, reqBody.Reaction)
+	if errors.Is(err, commandhandler.ErrInvalidReaction) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "id_pesan": msgID})
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"whatsapp_multi_session_general/commandhandler"
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// the stream is protected by the token, the dashboard can be served from another origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ServeEventStream upgrades the request to a websocket that receives the events of the sessions,
+// senders and events are optional comma separated filters, e.g. /events/stream?senders=62811&events=message,receipt
+func (h Handler) ServeEventStream(c *gin.Context) {
+	var filter stream.Filter
+	if senders := strings.TrimSpace(c.Query("senders")); senders != "" {
+		filter.Senders, _ = commandhandler.ValidateStringArrayAsStringArray(senders)
+	}
+	if events := strings.TrimSpace(c.Query("events")); events != "" {
+		filter.Events, _ = commandhandler.ValidateStringArrayAsStringArray(events)
+		for _, name := range filter.Events {
+			if !stringContains(primitive.WhatsappEvents, name) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "unknown event " + name, "events": primitive.WhatsappEvents})
+				return
+			}
+		}
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader already responded with the error
+		return
+	}
+	h.Stream.Serve(conn, filter)
+}
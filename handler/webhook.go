@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeWebhook returns the webhook of the sender
+func (h Handler) ServeWebhook(c *gin.Context) {
+	senderString := c.Param("sender")
+
+	response, err := h.Webhooks.Webhooks.Get(senderString)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "webhook tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// PutWebhook sets the webhook that receives the events of the sender
+func (h Handler) PutWebhook(c *gin.Context) {
+	senderString := c.Param("sender")
+
+	var reqBody struct {
+		URL     string   `json:"url" binding:"required"`
+		Events  []string `json:"events"`
+		Enabled *bool    `json:"enabled"`
+	}
+	if err := c.BindJSON(&reqBody); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "error decoding JSON"})
+		return
+	}
+
+	parsed, err := url.Parse(reqBody.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "url seharusnya diisi dengan http atau https url yang valid"})
+		return
+	}
+	for _, name := range reqBody.Events {
+		if !stringContains(primitive.WhatsappEvents, name) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "unknown event " + name, "events": primitive.WhatsappEvents})
+			return
+		}
+	}
+
+	webhook := repository.Webhook{
+		Session: senderString,
+		URL:     reqBody.URL,
+		Events:  reqBody.Events,
+		Enabled: reqBody.Enabled == nil || *reqBody.Enabled,
+	}
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	if err = h.Webhooks.Webhooks.Put(webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": webhook})
+}
+
+// DeleteWebhook removes the webhook of the sender, the events are sent to the webhook url of the config again
+func (h Handler) DeleteWebhook(c *gin.Context) {
+	senderString := c.Param("sender")
+
+	deleted, err := h.Webhooks.Webhooks.Delete(senderString)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"message": "webhook tidak ditemukan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success delete"})
+}
+
+// ServeDeadLetters returns the events that could not be delivered to the webhooks
+func (h Handler) ServeDeadLetters(c *gin.Context) {
+	senderString := c.Query("sender")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	response, err := h.Webhooks.Webhooks.ListDeadLetters(senderString, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "result": response})
+}
+
+// RedeliverDeadLetter sends a dead letter to the webhook again
+func (h Handler) RedeliverDeadLetter(c *gin.Context) {
+	eventID := c.Param("id")
+
+	status, err := h.Webhooks.Redeliver(eventID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "dead letter tidak ditemukan"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"message": err.Error(), "status": status})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "success", "status": status})
+}
@@ -23,6 +23,9 @@ func NewListener(commandhandler commandhandler.CommandHandler) Listener {
 // TriggerStartUp sends a signal to the repository and performs start up actions.
 // this call should be not initiated on event because we can just call it on the main.go
 func (l Listener) TriggerStartUp() {
+	// the media downloads do not survive a restart, they are retried when the media is requested
+	l.CommandHandler.ResetPendingMedia()
+
 	if config.Conf.StartUp.EnableAutoLogin {
 		fmt.Println("trigger TriggerStartUp for EnableAutoLogin is enabled")
 		l.CommandHandler.AutoLogin()
@@ -53,3 +56,22 @@ func (l Listener) TriggerShutDown() {
 		l.CommandHandler.AutoLogOut()
 	}
 }
+
+// ListenForWhatsappEvent calls the handle for every published whatsapp event of the sessions.
+// the handle is called on the goroutine of the whatsmeow client, it should not block.
+func (l Listener) ListenForWhatsappEvent(handle func(evt primitive.Event)) {
+	event.On(primitive.WhatsappEvent, event.ListenerFunc(func(e event.Event) error {
+		if evt, ok := e.Get(primitive.WhatsappEventKey).(primitive.Event); ok {
+			handle(evt)
+		}
+		return nil
+	}))
+}
+
+// ListenForShutdown calls the stop on the shutdown event, e.g. to flush a background worker.
+func (l Listener) ListenForShutdown(stop func()) {
+	event.On(primitive.ShutDownEvent, event.ListenerFunc(func(e event.Event) error {
+		stop()
+		return nil
+	}))
+}
@@ -25,6 +25,14 @@ func main() {
 	flag.StringVar(&config.Env, "env", "local", "A config name that used by server")
 	flag.Parse()
 
+	//run the cli subcommand (backup, restore) instead of the server
+	if flag.NArg() > 0 {
+		if err := boot.RunCommand(flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Create a new Gin router
 	router := gin.Default()
 
@@ -0,0 +1,23 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrNotFound  = errors.New("media not found")
+	ErrInvalidID = errors.New("invalid media id")
+)
+
+// Store keeps the content of the downloaded media, the metadata is kept on the wa_media table.
+// the local filesystem is the default, an object store can be used by implementing the same interface.
+type Store interface {
+	// Save writes the content of the media, an existing content of the same id is replaced
+	Save(ctx context.Context, id string, content io.Reader) (size int64, err error)
+	// Open returns the content of the media, ErrNotFound is returned when it does not exist
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	// Delete removes the content of the media, a missing media is not an error
+	Delete(ctx context.Context, id string) error
+}
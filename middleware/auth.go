@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"whatsapp_multi_session_general/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AdminTokenHeader  = "X-Admin-Token"
+	StreamTokenHeader = "X-Stream-Token"
+	MediaTokenHeader  = "X-Media-Token"
+
+	// tokenQuery is accepted because the browser websocket api and links can not set headers
+	tokenQuery = "token"
+)
+
+// AdminAuth only allows the request with the configured admin token,
+// the admin endpoints are disabled when auth.adminToken is not configured.
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Conf.Auth.AdminToken
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "admin endpoint is disabled, auth.adminToken is not configured"})
+			return
+		}
+
+		if !equalToken(requestToken(c, AdminTokenHeader), token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// StreamAuth only allows the request with the configured stream or admin token,
+// the token can also be sent on the token query parameter.
+func StreamAuth() gin.HandlerFunc {
+	return tokenAuth(StreamTokenHeader, "event stream is disabled, auth.streamToken is not configured", func() []string {
+		return []string{config.Conf.Auth.StreamToken, config.Conf.Auth.AdminToken}
+	})
+}
+
+// MediaAuth only allows the request with the configured media or admin token,
+// the token can also be sent on the token query parameter so the media can be linked.
+func MediaAuth() gin.HandlerFunc {
+	return tokenAuth(MediaTokenHeader, "media endpoint is disabled, auth.mediaToken is not configured", func() []string {
+		return []string{config.Conf.Auth.MediaToken, config.Conf.Auth.AdminToken}
+	})
+}
+
+// tokenAuth allows the request when its token equals one of the configured tokens,
+// the endpoint is disabled when none of them is configured.
+func tokenAuth(header, disabledMessage string, tokens func() []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		configured := false
+		for _, token := range tokens() {
+			configured = configured || token != ""
+		}
+		if !configured {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": disabledMessage})
+			return
+		}
+
+		given := requestToken(c, header)
+		if given == "" {
+			given = c.Query(tokenQuery)
+		}
+		for _, token := range tokens() {
+			if token != "" && equalToken(given, token) {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+	}
+}
+
+// requestToken reads the token from the given header, or from the bearer authorization header.
+func requestToken(c *gin.Context, header string) string {
+	if token := c.GetHeader(header); token != "" {
+		return token
+	}
+	authorization := c.GetHeader("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimPrefix(authorization, "Bearer ")
+	}
+	return ""
+}
+
+func equalToken(given, expected string) bool {
+	return given != "" && subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
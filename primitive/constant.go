@@ -3,3 +3,50 @@ package primitive
 const (
 	ShutDownEvent = "ShutDownEvent"
 )
+
+const (
+	PairingEventCode     = "code"
+	PairingEventSuccess  = "success"
+	PairingEventTimeout  = "timeout"
+	PairingEventError    = "error"
+	PairingEventMismatch = "mismatch"
+)
+
+const (
+	// WhatsappEvent is fired for every serialized whatsapp event of a session, the payload is under WhatsappEventKey
+	WhatsappEvent    = "WhatsappEvent"
+	WhatsappEventKey = "event"
+)
+
+const (
+	EventMessage        = "message"
+	EventReceipt        = "receipt"
+	EventConnected      = "connected"
+	EventDisconnected   = "disconnected"
+	EventLoggedOut      = "logged_out"
+	EventPairSuccess    = "pair_success"
+	EventStreamReplaced = "stream_replaced"
+	EventConnectFailure = "connect_failure"
+	EventTemporaryBan   = "temporary_ban"
+	EventPresence       = "presence"
+	EventChatPresence   = "chat_presence"
+	EventCall           = "call"
+	EventReaction       = "reaction"
+)
+
+// WhatsappEvents is every event type that is published, the webhook event filters are validated against it.
+var WhatsappEvents = []string{
+	EventMessage,
+	EventReceipt,
+	EventConnected,
+	EventDisconnected,
+	EventLoggedOut,
+	EventPairSuccess,
+	EventStreamReplaced,
+	EventConnectFailure,
+	EventTemporaryBan,
+	EventPresence,
+	EventChatPresence,
+	EventCall,
+	EventReaction,
+}
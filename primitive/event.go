@@ -0,0 +1,112 @@
+package primitive
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Event is the serialized form of a whatsapp event of a session, it is the body of the webhooks.
+type Event struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	Session   string      `json:"session"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// MessageEvent is the data of a message event.
+type MessageEvent struct {
+	MessageID string `json:"messageId"`
+	Chat      string `json:"chat"`
+	Sender    string `json:"sender"`
+	PushName  string `json:"pushName,omitempty"`
+	IsGroup   bool   `json:"isGroup"`
+	IsFromMe  bool   `json:"isFromMe"`
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	MimeType  string `json:"mimeType,omitempty"`
+	FileName  string `json:"fileName,omitempty"`
+	// MediaID and MediaURL are set when the media of the message is downloaded, see GET /media/:id
+	MediaID         string    `json:"mediaId,omitempty"`
+	MediaURL        string    `json:"mediaUrl,omitempty"`
+	QuotedMessageID string    `json:"quotedMessageId,omitempty"`
+	QuotedSender    string    `json:"quotedSender,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// ReactionEvent is the data of a reaction event, Removed is set when the reactor removed its reaction.
+// TargetIsFromMe is set when the reacted message is sent by the session.
+type ReactionEvent struct {
+	MessageID       string    `json:"messageId"`
+	Chat            string    `json:"chat"`
+	Sender          string    `json:"sender"`
+	PushName        string    `json:"pushName,omitempty"`
+	IsGroup         bool      `json:"isGroup"`
+	IsFromMe        bool      `json:"isFromMe"`
+	TargetMessageID string    `json:"targetMessageId"`
+	TargetSender    string    `json:"targetSender"`
+	TargetIsFromMe  bool      `json:"targetIsFromMe"`
+	Reaction        string    `json:"reaction,omitempty"`
+	Removed         bool      `json:"removed"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// ReceiptEvent is the data of a receipt event, Status is the outbound status the receipt stands for.
+type ReceiptEvent struct {
+	MessageIDs []string  `json:"messageIds"`
+	Chat       string    `json:"chat"`
+	Sender     string    `json:"sender"`
+	IsGroup    bool      `json:"isGroup"`
+	Type       string    `json:"type"`
+	Status     string    `json:"status,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// PresenceEvent is the data of a presence event (online, last seen) of a contact.
+type PresenceEvent struct {
+	From        string     `json:"from"`
+	Unavailable bool       `json:"unavailable"`
+	LastSeen    *time.Time `json:"lastSeen,omitempty"`
+}
+
+// ChatPresenceEvent is the data of a chat presence event, State is composing or paused.
+type ChatPresenceEvent struct {
+	Chat    string `json:"chat"`
+	Sender  string `json:"sender"`
+	IsGroup bool   `json:"isGroup"`
+	State   string `json:"state"`
+	Media   string `json:"media,omitempty"`
+}
+
+// CallEvent is the data of a call event, Rejected is set when the call is rejected by the call settings of the session.
+type CallEvent struct {
+	CallID      string    `json:"callId"`
+	From        string    `json:"from"`
+	CallCreator string    `json:"callCreator"`
+	IsVideo     bool      `json:"isVideo"`
+	IsGroup     bool      `json:"isGroup"`
+	Platform    string    `json:"platform,omitempty"`
+	Rejected    bool      `json:"rejected"`
+	RejectError string    `json:"rejectError,omitempty"`
+	Replied     bool      `json:"replied"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// ConnectionEvent is the data of the connection events (connected, disconnected, logged out, ...).
+type ConnectionEvent struct {
+	JID       string     `json:"jid,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Message   string     `json:"message,omitempty"`
+	Code      int        `json:"code,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// GenerateEventID returns a random id of an event, receivers can use it to drop a redelivered event.
+func GenerateEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -1,9 +1,26 @@
 package primitive
 
+import "time"
+
 type Devices struct {
-	PushName   string `json:"pushName"`
-	Platform   string `json:"platform"`
-	User       string `json:"user"`
-	Server     string `json:"server"`
-	IsLoggedIn bool   `json:"isLoggedIn"`
+	PushName       string    `json:"pushName"`
+	Platform       string    `json:"platform"`
+	User           string    `json:"user"`
+	Server         string    `json:"server"`
+	IsLoggedIn     bool      `json:"isLoggedIn"`
+	State          string    `json:"state"`
+	LastError      string    `json:"lastError,omitempty"`
+	StateUpdatedAt time.Time `json:"stateUpdatedAt"`
+}
+
+// PairingEvent is emitted while a session is waiting to be paired,
+// Event is one of code, success, mismatch, timeout or error.
+type PairingEvent struct {
+	Event   string `json:"event"`
+	Code    string `json:"code,omitempty"`
+	Image   string `json:"image,omitempty"`
+	Timeout int    `json:"timeout,omitempty"`
+	JID     string `json:"jid,omitempty"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
 }
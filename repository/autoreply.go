@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	MatchExact    = "exact"
+	MatchContains = "contains"
+	MatchRegex    = "regex"
+)
+
+// AutoReply is a keyword rule of a session, the first enabled rule that matches an incoming text is answered.
+type AutoReply struct {
+	ID            string `json:"id"`
+	Session       string `json:"session"`
+	Name          string `json:"name,omitempty"`
+	MatchType     string `json:"matchType"`
+	Pattern       string `json:"pattern"`
+	CaseSensitive bool   `json:"caseSensitive"`
+	// ReplyText is the text of the reply or the caption of the media reply
+	ReplyText     string `json:"replyText,omitempty"`
+	ReplyMediaURL string `json:"replyMediaUrl,omitempty"`
+	// Groups enables the rule on the group chats, only private chats are answered otherwise
+	Groups bool `json:"groups"`
+	// StartTime and EndTime (HH:MM) limit the rule to a time of the day, the window may cross midnight
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+	// Cooldown in seconds before the same contact is answered by the rule again
+	Cooldown int `json:"cooldown"`
+	// Priority orders the rules, the lowest is evaluated first
+	Priority  int       `json:"priority"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// AutoReplyRepository stores the auto-reply rules of the sessions and their last reply to every contact.
+type AutoReplyRepository struct {
+	db *sql.DB
+}
+
+func NewAutoReplyRepository(db *sql.DB) *AutoReplyRepository {
+	return &AutoReplyRepository{db: db}
+}
+
+const autoReplyColumns = `id, session, name, match_type, pattern, case_sensitive, reply_text, reply_media_url, in_groups,
+	start_time, end_time, timezone, cooldown, priority, enabled, created_at, updated_at`
+
+// List returns the rules of the session in the order they are evaluated.
+func (r *AutoReplyRepository) List(session string) ([]AutoReply, error) {
+	rows, err := r.db.Query(`SELECT `+autoReplyColumns+` FROM wa_auto_replies WHERE session = $1
+		ORDER BY priority, created_at, id`, session)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []AutoReply{}
+	for rows.Next() {
+		rule, err := scanAutoReply(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// Get returns a rule of the session, ErrNotFound is returned when the session has no such rule.
+func (r *AutoReplyRepository) Get(session, id string) (AutoReply, error) {
+	rule, err := scanAutoReply(r.db.QueryRow(`SELECT `+autoReplyColumns+` FROM wa_auto_replies
+		WHERE session = $1 AND id = $2`, session, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return rule, ErrNotFound
+	}
+	return rule, err
+}
+
+// Create stores a new rule.
+func (r *AutoReplyRepository) Create(rule AutoReply) error {
+	_, err := r.db.Exec(`INSERT INTO wa_auto_replies (`+autoReplyColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		rule.ID, rule.Session, rule.Name, rule.MatchType, rule.Pattern, rule.CaseSensitive, rule.ReplyText, rule.ReplyMediaURL,
+		rule.Groups, rule.StartTime, rule.EndTime, rule.Timezone, rule.Cooldown, rule.Priority, rule.Enabled,
+		toMillis(rule.CreatedAt), toMillis(rule.UpdatedAt))
+	return err
+}
+
+// Update replaces a rule of the session, it reports false when the session has no such rule.
+func (r *AutoReplyRepository) Update(rule AutoReply) (bool, error) {
+	result, err := r.db.Exec(`UPDATE wa_auto_replies SET
+		name = $1, match_type = $2, pattern = $3, case_sensitive = $4, reply_text = $5, reply_media_url = $6, in_groups = $7,
+		start_time = $8, end_time = $9, timezone = $10, cooldown = $11, priority = $12, enabled = $13, updated_at = $14
+		WHERE session = $15 AND id = $16`,
+		rule.Name, rule.MatchType, rule.Pattern, rule.CaseSensitive, rule.ReplyText, rule.ReplyMediaURL, rule.Groups,
+		rule.StartTime, rule.EndTime, rule.Timezone, rule.Cooldown, rule.Priority, rule.Enabled, toMillis(rule.UpdatedAt),
+		rule.Session, rule.ID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Delete removes a rule of the session with its cooldowns, it reports false when the session has no such rule.
+func (r *AutoReplyRepository) Delete(session, id string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM wa_auto_replies WHERE session = $1 AND id = $2`, session, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	_, err = r.db.Exec(`DELETE FROM wa_auto_reply_hits WHERE rule_id = $1`, id)
+	return true, err
+}
+
+// ClaimReply records a reply of the rule to the contact at the given time, it reports false without recording it
+// when the contact was already answered by the rule within the cooldown.
+func (r *AutoReplyRepository) ClaimReply(ruleID, contact string, at time.Time, cooldown time.Duration) (bool, error) {
+	result, err := r.db.Exec(`INSERT INTO wa_auto_reply_hits (rule_id, contact, replied_at) VALUES ($1, $2, $3)
+		ON CONFLICT (rule_id, contact) DO UPDATE SET replied_at = excluded.replied_at
+		WHERE wa_auto_reply_hits.replied_at <= $4`,
+		ruleID, contact, toMillis(at), toMillis(at.Add(-cooldown)))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanAutoReply(row interface {
+	Scan(dest ...interface{}) error
+}) (rule AutoReply, err error) {
+	var createdAt, updatedAt int64
+	err = row.Scan(&rule.ID, &rule.Session, &rule.Name, &rule.MatchType, &rule.Pattern, &rule.CaseSensitive, &rule.ReplyText,
+		&rule.ReplyMediaURL, &rule.Groups, &rule.StartTime, &rule.EndTime, &rule.Timezone, &rule.Cooldown, &rule.Priority,
+		&rule.Enabled, &createdAt, &updatedAt)
+	if err != nil {
+		return rule, err
+	}
+	rule.CreatedAt = fromMillis(createdAt)
+	rule.UpdatedAt = fromMillis(updatedAt)
+	return rule, nil
+}
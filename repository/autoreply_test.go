@@ -0,0 +1,42 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestClaimReply(t *testing.T) {
+	rules := repository.NewAutoReplyRepository(newTestDB(t))
+	rule := repository.AutoReply{ID: "r1", Session: "6281", MatchType: repository.MatchContains, Pattern: "harga", ReplyText: "cek katalog", Enabled: true}
+	if err := rules.Create(rule); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	start := time.Now()
+	cooldown := 10 * time.Minute
+	tests := []struct {
+		name    string
+		contact string
+		at      time.Time
+		want    bool
+	}{
+		{name: "first reply", contact: "6282@s.whatsapp.net", at: start, want: true},
+		{name: "within the cooldown", contact: "6282@s.whatsapp.net", at: start.Add(5 * time.Minute), want: false},
+		{name: "other contact", contact: "6283@s.whatsapp.net", at: start.Add(5 * time.Minute), want: true},
+		{name: "after the cooldown", contact: "6282@s.whatsapp.net", at: start.Add(cooldown), want: true},
+		{name: "cooldown from the last reply", contact: "6282@s.whatsapp.net", at: start.Add(cooldown + time.Minute), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rules.ClaimReply(rule.ID, tt.contact, tt.at, cooldown)
+			if err != nil {
+				t.Fatalf("ClaimReply: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ClaimReply = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// BusinessHours is the opening schedule of a session, the away message is sent to the contacts
+// that write outside of it.
+type BusinessHours struct {
+	Session  string `json:"session"`
+	Timezone string `json:"timezone,omitempty"`
+	// Hours are schedule strings (minute hour day month weekday) of the minutes the business is open
+	Hours []string `json:"hours"`
+	// Holidays are dates (YYYY-MM-DD) the business is closed the whole day
+	Holidays    []string  `json:"holidays"`
+	AwayMessage string    `json:"awayMessage"`
+	Enabled     bool      `json:"enabled"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// BusinessHoursRepository stores the business hours of the sessions and the away messages that were sent.
+type BusinessHoursRepository struct {
+	db *sql.DB
+}
+
+func NewBusinessHoursRepository(db *sql.DB) *BusinessHoursRepository {
+	return &BusinessHoursRepository{db: db}
+}
+
+// Get returns the business hours of the session, ErrNotFound is returned when the session has none.
+func (r *BusinessHoursRepository) Get(session string) (BusinessHours, error) {
+	var hours BusinessHours
+	var schedules, holidays string
+	var createdAt, updatedAt int64
+	err := r.db.QueryRow(`SELECT session, timezone, hours, holidays, away_message, enabled, created_at, updated_at
+		FROM wa_business_hours WHERE session = $1`, session).
+		Scan(&hours.Session, &hours.Timezone, &schedules, &holidays, &hours.AwayMessage, &hours.Enabled, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return hours, ErrNotFound
+	}
+	if err != nil {
+		return hours, err
+	}
+	// a schedule has commas of its own, the schedules are kept one per line
+	hours.Hours = splitLines(schedules)
+	hours.Holidays = splitList(holidays)
+	hours.CreatedAt = fromMillis(createdAt)
+	hours.UpdatedAt = fromMillis(updatedAt)
+	return hours, nil
+}
+
+// Put creates or replaces the business hours of the session.
+func (r *BusinessHoursRepository) Put(hours BusinessHours) error {
+	now := toMillis(time.Now())
+	_, err := r.db.Exec(`INSERT INTO wa_business_hours
+		(session, timezone, hours, holidays, away_message, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		ON CONFLICT (session) DO UPDATE SET
+		timezone = excluded.timezone, hours = excluded.hours, holidays = excluded.holidays,
+		away_message = excluded.away_message, enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		hours.Session, hours.Timezone, strings.Join(hours.Hours, "\n"), strings.Join(hours.Holidays, ","), hours.AwayMessage,
+		hours.Enabled, now)
+	return err
+}
+
+// Delete removes the business hours of the session with its sent away messages,
+// it reports false when the session has none.
+func (r *BusinessHoursRepository) Delete(session string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM wa_business_hours WHERE session = $1`, session)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	_, err = r.db.Exec(`DELETE FROM wa_away_replies WHERE session = $1`, session)
+	return true, err
+}
+
+// ClaimAway records the away message of the closed window that started at windowStart to the contact,
+// it reports false without recording it when the contact already got the away message of the window.
+func (r *BusinessHoursRepository) ClaimAway(session, contact string, windowStart, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`INSERT INTO wa_away_replies (session, contact, window_start, sent_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (session, contact) DO UPDATE SET window_start = excluded.window_start, sent_at = excluded.sent_at
+		WHERE wa_away_replies.window_start < excluded.window_start`,
+		session, contact, toMillis(windowStart), toMillis(at))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func splitLines(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, "\n") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
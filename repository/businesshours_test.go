@@ -0,0 +1,40 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestClaimAway(t *testing.T) {
+	hours := repository.NewBusinessHoursRepository(newTestDB(t))
+
+	closed := time.Date(2024, 3, 4, 17, 0, 0, 0, time.UTC)
+	nextClosed := closed.Add(24 * time.Hour)
+	tests := []struct {
+		name        string
+		session     string
+		contact     string
+		windowStart time.Time
+		want        bool
+	}{
+		{name: "first message of the window", session: "6281", contact: "6282", windowStart: closed, want: true},
+		{name: "same window", session: "6281", contact: "6282", windowStart: closed, want: false},
+		{name: "other contact", session: "6281", contact: "6283", windowStart: closed, want: true},
+		{name: "other session", session: "6289", contact: "6282", windowStart: closed, want: true},
+		{name: "next window", session: "6281", contact: "6282", windowStart: nextClosed, want: true},
+		{name: "older window", session: "6281", contact: "6282", windowStart: closed, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hours.ClaimAway(tt.session, tt.contact, tt.windowStart, tt.windowStart.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("ClaimAway: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ClaimAway = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// CallSettings is how the incoming calls of a session are handled.
+type CallSettings struct {
+	Session string `json:"session"`
+	// Reject declines the incoming calls, the caller gets the reply when it is set
+	Reject    bool      `json:"reject"`
+	Reply     string    `json:"reply,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Call is a call received by one of the sessions.
+type Call struct {
+	Session     string    `json:"session"`
+	CallID      string    `json:"callId"`
+	Caller      string    `json:"caller"`
+	CallCreator string    `json:"callCreator,omitempty"`
+	IsVideo     bool      `json:"isVideo"`
+	IsGroup     bool      `json:"isGroup"`
+	Platform    string    `json:"platform,omitempty"`
+	Rejected    bool      `json:"rejected"`
+	RejectError string    `json:"rejectError,omitempty"`
+	Replied     bool      `json:"replied"`
+	Timestamp   time.Time `json:"timestamp"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// CallRepository stores the call settings of the sessions and the received calls.
+type CallRepository struct {
+	db *sql.DB
+}
+
+func NewCallRepository(db *sql.DB) *CallRepository {
+	return &CallRepository{db: db}
+}
+
+// GetSettings returns the call settings of the session, ErrNotFound is returned when the session has none.
+func (r *CallRepository) GetSettings(session string) (CallSettings, error) {
+	var settings CallSettings
+	var createdAt, updatedAt int64
+	err := r.db.QueryRow(`SELECT session, reject, reply, created_at, updated_at FROM wa_call_settings WHERE session = $1`, session).
+		Scan(&settings.Session, &settings.Reject, &settings.Reply, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return settings, ErrNotFound
+	}
+	if err != nil {
+		return settings, err
+	}
+	settings.CreatedAt = fromMillis(createdAt)
+	settings.UpdatedAt = fromMillis(updatedAt)
+	return settings, nil
+}
+
+// PutSettings creates or replaces the call settings of the session.
+func (r *CallRepository) PutSettings(settings CallSettings) error {
+	now := toMillis(time.Now())
+	_, err := r.db.Exec(`INSERT INTO wa_call_settings (session, reject, reply, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (session) DO UPDATE SET
+		reject = excluded.reject, reply = excluded.reply, updated_at = excluded.updated_at`,
+		settings.Session, settings.Reject, settings.Reply, now)
+	return err
+}
+
+// DeleteSettings removes the call settings of the session, it reports false when the session has none.
+func (r *CallRepository) DeleteSettings(session string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM wa_call_settings WHERE session = $1`, session)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CreateCall stores a received call, a call that is received again is ignored.
+func (r *CallRepository) CreateCall(call Call) error {
+	if call.CreatedAt.IsZero() {
+		call.CreatedAt = time.Now()
+	}
+	_, err := r.db.Exec(`INSERT INTO wa_calls
+		(session, call_id, caller, call_creator, is_video, is_group, platform, rejected, reject_error, replied, timestamp, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (session, call_id) DO NOTHING`,
+		call.Session, call.CallID, call.Caller, call.CallCreator, call.IsVideo, call.IsGroup, call.Platform, call.Rejected,
+		call.RejectError, call.Replied, toMillis(call.Timestamp), toMillis(call.CreatedAt))
+	return err
+}
+
+// ListCalls returns the calls of the session newest first.
+func (r *CallRepository) ListCalls(session string, limit, offset int) ([]Call, error) {
+	rows, err := r.db.Query(`SELECT session, call_id, caller, call_creator, is_video, is_group, platform, rejected, reject_error,
+		replied, timestamp, created_at
+		FROM wa_calls WHERE session = $1
+		ORDER BY timestamp DESC, call_id
+		LIMIT $2 OFFSET $3`, session, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calls := []Call{}
+	for rows.Next() {
+		var call Call
+		var timestamp, createdAt int64
+		err = rows.Scan(&call.Session, &call.CallID, &call.Caller, &call.CallCreator, &call.IsVideo, &call.IsGroup, &call.Platform,
+			&call.Rejected, &call.RejectError, &call.Replied, &timestamp, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		call.Timestamp = fromMillis(timestamp)
+		call.CreatedAt = fromMillis(createdAt)
+		calls = append(calls, call)
+	}
+	return calls, rows.Err()
+}
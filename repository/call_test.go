@@ -0,0 +1,63 @@
+package repository_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestListCalls(t *testing.T) {
+	calls := repository.NewCallRepository(newTestDB(t))
+
+	now := time.Now().Truncate(time.Millisecond)
+	for _, call := range []repository.Call{
+		{Session: "6281", CallID: "c1", Caller: "6282@s.whatsapp.net", Timestamp: now.Add(-2 * time.Minute)},
+		{Session: "6281", CallID: "c2", Caller: "6283@s.whatsapp.net", IsVideo: true, Rejected: true, Timestamp: now.Add(-time.Minute)},
+		{Session: "6281", CallID: "c3", Caller: "6282@s.whatsapp.net", Timestamp: now},
+		{Session: "6289", CallID: "c4", Caller: "6282@s.whatsapp.net", Timestamp: now},
+		// a call that is received again is ignored
+		{Session: "6281", CallID: "c1", Caller: "6282@s.whatsapp.net", Replied: true, Timestamp: now.Add(time.Minute)},
+	} {
+		if err := calls.CreateCall(call); err != nil {
+			t.Fatalf("CreateCall %s: %v", call.CallID, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		limit   int
+		offset  int
+		want    []string
+	}{
+		{name: "newest first", session: "6281", limit: 10, want: []string{"c3", "c2", "c1"}},
+		{name: "paged", session: "6281", limit: 1, offset: 1, want: []string{"c2"}},
+		{name: "other session", session: "6289", limit: 10, want: []string{"c4"}},
+		{name: "unknown session", session: "6288", limit: 10, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calls.ListCalls(tt.session, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("ListCalls: %v", err)
+			}
+			ids := []string{}
+			for _, call := range got {
+				ids = append(ids, call.CallID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Fatalf("ListCalls = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	got, err := calls.ListCalls("6281", 1, 1)
+	if err != nil {
+		t.Fatalf("ListCalls: %v", err)
+	}
+	if call := got[0]; !call.IsVideo || !call.Rejected || call.Replied || !call.Timestamp.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("call = %+v, want the stored video call", call)
+	}
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	DirectionInbound  = "in"
+	DirectionOutbound = "out"
+
+	userServer = "s.whatsapp.net"
+)
+
+// InboundMessage is a message received by one of the sessions.
+type InboundMessage struct {
+	Session   string `json:"session"`
+	Chat      string `json:"chat"`
+	MessageID string `json:"messageId"`
+	Sender    string `json:"sender"`
+	PushName  string `json:"pushName,omitempty"`
+	IsGroup   bool   `json:"isGroup"`
+	Type      string `json:"type"`
+	// Text is the text of a text message or the caption of a media message
+	Text            string    `json:"text,omitempty"`
+	MimeType        string    `json:"mimeType,omitempty"`
+	FileName        string    `json:"fileName,omitempty"`
+	MediaID         string    `json:"mediaId,omitempty"`
+	QuotedMessageID string    `json:"quotedMessageId,omitempty"`
+	QuotedSender    string    `json:"quotedSender,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// Chat is a conversation of a session with its last activity, both directions are counted.
+type Chat struct {
+	Chat           string     `json:"chat"`
+	IsGroup        bool       `json:"isGroup"`
+	LastActivityAt time.Time  `json:"lastActivityAt"`
+	LastInboundAt  *time.Time `json:"lastInboundAt,omitempty"`
+	InboundCount   int64      `json:"inboundCount"`
+	OutboundCount  int64      `json:"outboundCount"`
+}
+
+// TimelineEntry is a single message of a chat timeline, inbound or outbound.
+type TimelineEntry struct {
+	Direction       string `json:"direction"`
+	MessageID       string `json:"messageId"`
+	Sender          string `json:"sender"`
+	Type            string `json:"type"`
+	Text            string `json:"text,omitempty"`
+	FileName        string `json:"fileName,omitempty"`
+	MediaID         string `json:"mediaId,omitempty"`
+	QuotedMessageID string `json:"quotedMessageId,omitempty"`
+	// Status is the delivery status of an outbound message
+	Status    string    `json:"status,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// CreateInbound stores a received message, a message that is received again (e.g. a retry) is ignored.
+func (r *MessageRepository) CreateInbound(msg InboundMessage) error {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	_, err := r.db.Exec(`INSERT INTO wa_inbound_messages
+		(session, chat, message_id, sender, push_name, is_group, type, text, mime_type, file_name,
+		media_id, quoted_id, quoted_sender, timestamp, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (session, chat, message_id) DO NOTHING`,
+		msg.Session, msg.Chat, msg.MessageID, msg.Sender, msg.PushName, msg.IsGroup, msg.Type, msg.Text, msg.MimeType, msg.FileName,
+		msg.MediaID, msg.QuotedMessageID, msg.QuotedSender, toMillis(msg.Timestamp), toMillis(msg.CreatedAt))
+	return err
+}
+
+// ListChats returns the chats of the session ordered by the last activity, newest first.
+func (r *MessageRepository) ListChats(session string, limit, offset int) ([]Chat, error) {
+	rows, err := r.db.Query(`SELECT chat, MAX(ts), MAX(CASE WHEN inbound = 1 THEN ts END), SUM(inbound), SUM(1 - inbound)
+		FROM (
+			SELECT chat, timestamp AS ts, 1 AS inbound FROM wa_inbound_messages WHERE session = $1
+			UNION ALL
+			SELECT recipient, COALESCE(sent_at, created_at), 0 FROM wa_outbound_messages WHERE sender = $1
+		) activity
+		GROUP BY chat
+		ORDER BY MAX(ts) DESC, chat
+		LIMIT $2 OFFSET $3`, session, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := []Chat{}
+	for rows.Next() {
+		var chat Chat
+		var lastActivityAt int64
+		var lastInboundAt sql.NullInt64
+		if err = rows.Scan(&chat.Chat, &lastActivityAt, &lastInboundAt, &chat.InboundCount, &chat.OutboundCount); err != nil {
+			return nil, err
+		}
+		chat.IsGroup = strings.HasSuffix(chat.Chat, "@g.us")
+		chat.LastActivityAt = fromMillis(lastActivityAt)
+		chat.LastInboundAt = fromNullMillis(lastInboundAt)
+		chats = append(chats, chat)
+	}
+	return chats, rows.Err()
+}
+
+// ListTimeline returns the messages of the chat in both directions that come after the cursor, newest first.
+// the cursor is the timestamp and the message id of the last message of the previous page, the messages of the same
+// timestamp are ordered by their id. An empty beforeID returns the messages older than before.
+func (r *MessageRepository) ListTimeline(session, chat string, before time.Time, beforeID string, limit int) ([]TimelineEntry, error) {
+	rows, err := r.db.Query(`SELECT direction, message_id, sender, type, text, file_name, media_id, quoted_id, status, ts
+		FROM (
+			SELECT 'in' AS direction, message_id, sender, type, text, file_name, media_id, quoted_id, '' AS status, timestamp AS ts
+			FROM wa_inbound_messages WHERE session = $1 AND chat = $2
+			UNION ALL
+			SELECT 'out', message_id, sender, type, body, file_name, '', '', status, COALESCE(sent_at, created_at)
+			FROM wa_outbound_messages WHERE sender = $1 AND recipient = $2
+		) timeline
+		WHERE ts < $3 OR (ts = $3 AND $4 <> '' AND message_id > $4)
+		ORDER BY ts DESC, message_id
+		LIMIT $5`, session, chat, toMillis(before), beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []TimelineEntry{}
+	for rows.Next() {
+		var entry TimelineEntry
+		var ts int64
+		err = rows.Scan(&entry.Direction, &entry.MessageID, &entry.Sender, &entry.Type, &entry.Text, &entry.FileName,
+			&entry.MediaID, &entry.QuotedMessageID, &entry.Status, &ts)
+		if err != nil {
+			return nil, err
+		}
+		entry.Timestamp = fromMillis(ts)
+		// the outbound log keeps the session number, shown as a jid like the inbound senders
+		if entry.Direction == DirectionOutbound && !strings.Contains(entry.Sender, "@") {
+			entry.Sender += "@" + userServer
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetChatMessage returns a message of the chat in either direction, e.g. the message that is quoted by a reply.
+// ErrNotFound is returned when the message is not on the message log.
+func (r *MessageRepository) GetChatMessage(session, chat, messageID string) (TimelineEntry, error) {
+	entry := TimelineEntry{Direction: DirectionInbound, MessageID: messageID}
+	var ts int64
+	err := r.db.QueryRow(`SELECT sender, type, text, file_name, media_id, quoted_id, timestamp
+		FROM wa_inbound_messages WHERE session = $1 AND chat = $2 AND message_id = $3`, session, chat, messageID).
+		Scan(&entry.Sender, &entry.Type, &entry.Text, &entry.FileName, &entry.MediaID, &entry.QuotedMessageID, &ts)
+	if errors.Is(err, sql.ErrNoRows) {
+		entry.Direction = DirectionOutbound
+		err = r.db.QueryRow(`SELECT sender, type, body, file_name, status, COALESCE(sent_at, created_at)
+			FROM wa_outbound_messages WHERE sender = $1 AND recipient = $2 AND message_id = $3`, session, chat, messageID).
+			Scan(&entry.Sender, &entry.Type, &entry.Text, &entry.FileName, &entry.Status, &ts)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return entry, ErrNotFound
+	}
+	if err != nil {
+		return entry, err
+	}
+	entry.Timestamp = fromMillis(ts)
+	if entry.Direction == DirectionOutbound && !strings.Contains(entry.Sender, "@") {
+		entry.Sender += "@" + userServer
+	}
+	return entry, nil
+}
@@ -0,0 +1,56 @@
+package repository_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestListTimelinePagesThroughSameTimestamp(t *testing.T) {
+	messages := repository.NewMessageRepository(newTestDB(t))
+	chat := "6282@s.whatsapp.net"
+
+	// whatsapp timestamps have a second resolution, several messages share the same timestamp
+	second := time.Now().Truncate(time.Second)
+	var want []string
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("IN%d", i)
+		err := messages.CreateInbound(repository.InboundMessage{
+			Session: "6281", Chat: chat, MessageID: id, Sender: chat, Type: "text", Timestamp: second,
+		})
+		if err != nil {
+			t.Fatalf("CreateInbound: %v", err)
+		}
+		want = append(want, id)
+	}
+	err := messages.CreateInbound(repository.InboundMessage{
+		Session: "6281", Chat: chat, MessageID: "OLD", Sender: chat, Type: "text", Timestamp: second.Add(-time.Second),
+	})
+	if err != nil {
+		t.Fatalf("CreateInbound: %v", err)
+	}
+	want = append(want, "OLD")
+
+	var got []string
+	before, beforeID := time.Now().Add(time.Second), ""
+	for page := 0; page < 10; page++ {
+		entries, err := messages.ListTimeline("6281", chat, before, beforeID, 2)
+		if err != nil {
+			t.Fatalf("ListTimeline: %v", err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		for _, entry := range entries {
+			got = append(got, entry.MessageID)
+		}
+		last := entries[len(entries)-1]
+		before, beforeID = last.Timestamp, last.MessageID
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
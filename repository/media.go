@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	MediaPending = "pending"
+	MediaReady   = "ready"
+	MediaFailed  = "failed"
+)
+
+// Media is a downloaded media of an inbound message. Attempts is the number of its downloads,
+// Message is the base64 of the message it is downloaded from.
+type Media struct {
+	ID        string    `json:"id"`
+	Session   string    `json:"session"`
+	Chat      string    `json:"chat"`
+	MessageID string    `json:"messageId"`
+	Type      string    `json:"type"`
+	MimeType  string    `json:"mimeType,omitempty"`
+	FileName  string    `json:"fileName,omitempty"`
+	Size      int64     `json:"size"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Attempts  int       `json:"attempts"`
+	Message   string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// MediaRepository stores the metadata of the downloaded media.
+type MediaRepository struct {
+	db *sql.DB
+}
+
+func NewMediaRepository(db *sql.DB) *MediaRepository {
+	return &MediaRepository{db: db}
+}
+
+// Create records a pending media, it reports false when the media is already recorded (e.g. a redelivered message).
+func (r *MediaRepository) Create(media Media) (bool, error) {
+	now := toMillis(time.Now())
+	result, err := r.db.Exec(`INSERT INTO wa_media
+		(id, session, chat, message_id, type, mime_type, file_name, size, status, message, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $9, 1, $10, $10)
+		ON CONFLICT (id) DO NOTHING`,
+		media.ID, media.Session, media.Chat, media.MessageID, media.Type, media.MimeType, media.FileName, MediaPending, media.Message, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// SetReady marks the media as downloaded.
+func (r *MediaRepository) SetReady(id string, size int64) error {
+	_, err := r.db.Exec(`UPDATE wa_media SET status = $1, size = $2, error = '', updated_at = $3 WHERE id = $4`,
+		MediaReady, size, toMillis(time.Now()), id)
+	return err
+}
+
+// SetFailed marks the media as failed with the reason.
+func (r *MediaRepository) SetFailed(id string, reason string) error {
+	_, err := r.db.Exec(`UPDATE wa_media SET status = $1, error = $2, updated_at = $3 WHERE id = $4`,
+		MediaFailed, reason, toMillis(time.Now()), id)
+	return err
+}
+
+// Retry marks a failed media as pending again to download it once more, it reports false when the media is not failed,
+// has no message to download it from or was downloaded maxAttempts times already.
+func (r *MediaRepository) Retry(id string, maxAttempts int) (bool, error) {
+	result, err := r.db.Exec(`UPDATE wa_media SET status = $1, error = '', attempts = attempts + 1, updated_at = $2
+		WHERE id = $3 AND status = $4 AND message <> '' AND attempts < $5`,
+		MediaPending, toMillis(time.Now()), id, MediaFailed, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FailPending marks the media that are pending since before as failed with the reason, it returns the number of media.
+// The download of a media does not survive a restart, its pending row is failed on start up so it can be retried.
+func (r *MediaRepository) FailPending(before time.Time, reason string) (int64, error) {
+	result, err := r.db.Exec(`UPDATE wa_media SET status = $1, error = $2, updated_at = $3 WHERE status = $4 AND updated_at < $5`,
+		MediaFailed, reason, toMillis(time.Now()), MediaPending, toMillis(before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Get returns the media, ErrNotFound is returned when it is not recorded.
+func (r *MediaRepository) Get(id string) (Media, error) {
+	var media Media
+	var createdAt, updatedAt int64
+	err := r.db.QueryRow(`SELECT id, session, chat, message_id, type, mime_type, file_name, size, status, error,
+		attempts, message, created_at, updated_at
+		FROM wa_media WHERE id = $1`, id).
+		Scan(&media.ID, &media.Session, &media.Chat, &media.MessageID, &media.Type, &media.MimeType, &media.FileName,
+			&media.Size, &media.Status, &media.Error, &media.Attempts, &media.Message, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return media, ErrNotFound
+	}
+	if err != nil {
+		return media, err
+	}
+	media.CreatedAt = fromMillis(createdAt)
+	media.UpdatedAt = fromMillis(updatedAt)
+	return media, nil
+}
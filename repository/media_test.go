@@ -0,0 +1,74 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestMediaRetry(t *testing.T) {
+	files := repository.NewMediaRepository(newTestDB(t))
+
+	for _, file := range []repository.Media{
+		{ID: "interrupted", Message: "bWVzc2FnZQ=="},
+		{ID: "ready", Message: "bWVzc2FnZQ=="},
+		{ID: "no message"},
+	} {
+		file.Session, file.Chat, file.MessageID, file.Type = "6281", "6282@s.whatsapp.net", file.ID, "image"
+		if _, err := files.Create(file); err != nil {
+			t.Fatalf("Create %s: %v", file.ID, err)
+		}
+	}
+	if err := files.SetReady("ready", 10); err != nil {
+		t.Fatalf("SetReady: %v", err)
+	}
+
+	// a restart fails the media that were still pending
+	count, err := files.FailPending(time.Now().Add(time.Second), "interrupted")
+	if err != nil || count != 2 {
+		t.Fatalf("FailPending = %d, %v, want 2", count, err)
+	}
+	if file, _ := files.Get("ready"); file.Status != repository.MediaReady {
+		t.Fatalf("ready media status = %s, want %s", file.Status, repository.MediaReady)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		fail bool
+		want bool
+	}{
+		{name: "failed", id: "interrupted", want: true},
+		{name: "already pending", id: "interrupted", want: false},
+		{name: "second attempt", id: "interrupted", fail: true, want: true},
+		{name: "max attempts", id: "interrupted", fail: true, want: false},
+		{name: "ready", id: "ready", want: false},
+		{name: "without message", id: "no message", want: false},
+		{name: "unknown", id: "unknown", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fail {
+				if err := files.SetFailed(tt.id, "timeout"); err != nil {
+					t.Fatalf("SetFailed: %v", err)
+				}
+			}
+			got, err := files.Retry(tt.id, 3)
+			if err != nil {
+				t.Fatalf("Retry: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Retry = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	file, err := files.Get("interrupted")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if file.Status != repository.MediaFailed || file.Attempts != 3 || file.Message != "bWVzc2FnZQ==" {
+		t.Fatalf("media = %s after %d attempts with message %q, want failed after 3", file.Status, file.Attempts, file.Message)
+	}
+}
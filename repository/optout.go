@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// OptOutRepository stores the contacts that do not want the automatic replies of a session anymore.
+type OptOutRepository struct {
+	db *sql.DB
+}
+
+func NewOptOutRepository(db *sql.DB) *OptOutRepository {
+	return &OptOutRepository{db: db}
+}
+
+// Add opts the contact out of the automatic replies of the session.
+func (r *OptOutRepository) Add(session, contact string) error {
+	_, err := r.db.Exec(`INSERT INTO wa_bot_opt_outs (session, contact, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (session, contact) DO NOTHING`, session, contact, toMillis(time.Now()))
+	return err
+}
+
+// Remove opts the contact in again, it reports false when the contact was not opted out.
+func (r *OptOutRepository) Remove(session, contact string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM wa_bot_opt_outs WHERE session = $1 AND contact = $2`, session, contact)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Has reports whether the contact is opted out of the automatic replies of the session.
+func (r *OptOutRepository) Has(session, contact string) (bool, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM wa_bot_opt_outs WHERE session = $1 AND contact = $2`, session, contact).Scan(&count)
+	return count > 0, err
+}
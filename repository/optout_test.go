@@ -0,0 +1,46 @@
+package repository_test
+
+import (
+	"testing"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestOptOut(t *testing.T) {
+	optOuts := repository.NewOptOutRepository(newTestDB(t))
+
+	has := func(session, contact string) bool {
+		t.Helper()
+		ok, err := optOuts.Has(session, contact)
+		if err != nil {
+			t.Fatalf("Has: %v", err)
+		}
+		return ok
+	}
+
+	if has("6281", "6282") {
+		t.Fatalf("contact is opted out before Add")
+	}
+	for i := 0; i < 2; i++ {
+		if err := optOuts.Add("6281", "6282"); err != nil {
+			t.Fatalf("Add %d: %v", i, err)
+		}
+	}
+	if !has("6281", "6282") {
+		t.Fatalf("contact is not opted out after Add")
+	}
+	if has("6289", "6282") {
+		t.Fatalf("contact is opted out of another session")
+	}
+
+	removed, err := optOuts.Remove("6281", "6282")
+	if err != nil || !removed {
+		t.Fatalf("Remove = %v, %v, want true", removed, err)
+	}
+	if has("6281", "6282") {
+		t.Fatalf("contact is opted out after Remove")
+	}
+	if removed, err = optOuts.Remove("6281", "6282"); err != nil || removed {
+		t.Fatalf("second Remove = %v, %v, want false", removed, err)
+	}
+}
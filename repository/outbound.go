@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+	StatusPlayed    = "played"
+	StatusFailed    = "failed"
+)
+
+// statusOrder is the order of the delivery statuses, a status never goes back to a lower one
+// e.g. a late delivered receipt does not overwrite read. failed is only set before the message is delivered.
+var statusOrder = map[string][]string{
+	StatusSent:      {StatusPending},
+	StatusDelivered: {StatusPending, StatusSent},
+	StatusRead:      {StatusPending, StatusSent, StatusDelivered},
+	StatusPlayed:    {StatusPending, StatusSent, StatusDelivered, StatusRead},
+	StatusFailed:    {StatusPending, StatusSent},
+}
+
+// statusColumn is the timestamp column of every status.
+var statusColumn = map[string]string{
+	StatusSent:      "sent_at",
+	StatusDelivered: "delivered_at",
+	StatusRead:      "read_at",
+	StatusPlayed:    "played_at",
+	StatusFailed:    "failed_at",
+}
+
+// OutboundMessage is a message sent by one of the sessions and its delivery status.
+type OutboundMessage struct {
+	Sender      string     `json:"sender"`
+	MessageID   string     `json:"messageId"`
+	Recipient   string     `json:"recipient"`
+	Type        string     `json:"type"`
+	Body        string     `json:"body,omitempty"`
+	FileName    string     `json:"fileName,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	SentAt      *time.Time `json:"sentAt,omitempty"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
+	PlayedAt    *time.Time `json:"playedAt,omitempty"`
+	FailedAt    *time.Time `json:"failedAt,omitempty"`
+	// EditedAt is set when the text was edited, Body is the edited text. RevokedAt is set when it was deleted for everyone
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	// Receipts is filled for group messages, one per participant that sent a receipt
+	Receipts []Receipt `json:"receipts,omitempty"`
+}
+
+// MessageRepository stores the message log of every session on the application tables.
+type MessageRepository struct {
+	db *sql.DB
+}
+
+func NewMessageRepository(db *sql.DB) *MessageRepository {
+	return &MessageRepository{db: db}
+}
+
+// CreateOutbound records a new outbound message, the status is pending when it is not set.
+func (r *MessageRepository) CreateOutbound(msg OutboundMessage) error {
+	if msg.Status == "" {
+		msg.Status = StatusPending
+	}
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.Exec(`INSERT INTO wa_outbound_messages
+		(sender, message_id, recipient, type, body, file_name, status, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (sender, message_id) DO NOTHING`,
+		msg.Sender, msg.MessageID, msg.Recipient, msg.Type, msg.Body, msg.FileName, msg.Status, msg.Error,
+		toMillis(msg.CreatedAt), toMillis(msg.CreatedAt))
+	return err
+}
+
+// UpdateOutboundStatus moves the message to the status and sets the timestamp of the status if it is not set yet.
+// it reports false when the message is not on the log, e.g. it was sent from the phone.
+func (r *MessageRepository) UpdateOutboundStatus(sender, messageID, status string, at time.Time, errMsg string) (bool, error) {
+	column, ok := statusColumn[status]
+	if !ok {
+		return false, fmt.Errorf("unknown message status %q", status)
+	}
+	previous := "'" + strings.Join(statusOrder[status], "', '") + "'"
+
+	// a late receipt still fills its timestamp (delivered after read), except failed of a delivered message
+	timestamp := fmt.Sprintf("COALESCE(%s, $3)", column)
+	if status == StatusFailed {
+		timestamp = fmt.Sprintf("CASE WHEN status IN (%s) THEN COALESCE(%s, $3) ELSE %s END", previous, column, column)
+	}
+
+	// the column and the statuses come from the maps above, never from the input
+	query := fmt.Sprintf(`UPDATE wa_outbound_messages SET
+		status = CASE WHEN status IN (%[1]s) THEN $1 ELSE status END,
+		error = CASE WHEN status IN (%[1]s) AND $2 <> '' THEN $2 ELSE error END,
+		%[2]s = %[3]s,
+		updated_at = $4
+		WHERE sender = $5 AND message_id = $6`, previous, column, timestamp)
+
+	result, err := r.db.Exec(query, status, errMsg, toMillis(at), toMillis(time.Now()), sender, messageID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MarkOutboundEdited replaces the text of the message with the edited one,
+// it reports false when the message is not on the log.
+func (r *MessageRepository) MarkOutboundEdited(sender, messageID, body string, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE wa_outbound_messages SET body = $1, edited_at = $2, updated_at = $3
+		WHERE sender = $4 AND message_id = $5`, body, toMillis(at), toMillis(time.Now()), sender, messageID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkOutboundRevoked records that the message was deleted for everyone, it reports false when the message
+// is not on the log or it was already revoked.
+func (r *MessageRepository) MarkOutboundRevoked(sender, messageID string, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE wa_outbound_messages SET revoked_at = $1, updated_at = $2
+		WHERE sender = $3 AND message_id = $4 AND revoked_at IS NULL`, toMillis(at), toMillis(time.Now()), sender, messageID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Receipt is the delivery status of an outbound group message for a single participant.
+type Receipt struct {
+	Participant string     `json:"participant"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time `json:"readAt,omitempty"`
+	PlayedAt    *time.Time `json:"playedAt,omitempty"`
+}
+
+// RecordReceipt stores the receipt of a group participant, only delivered, read and played are recorded.
+func (r *MessageRepository) RecordReceipt(sender, messageID, participant, status string, at time.Time) error {
+	column, ok := statusColumn[status]
+	if !ok || status == StatusSent || status == StatusFailed {
+		return fmt.Errorf("status %q is not a participant receipt", status)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO wa_outbound_receipts (sender, message_id, participant, %[1]s, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (sender, message_id, participant) DO UPDATE SET
+		%[1]s = COALESCE(wa_outbound_receipts.%[1]s, excluded.%[1]s),
+		updated_at = excluded.updated_at`, column)
+	_, err := r.db.Exec(query, sender, messageID, participant, toMillis(at), toMillis(time.Now()))
+	return err
+}
+
+const selectOutbound = `SELECT sender, message_id, recipient, type, body, file_name, status, error,
+	created_at, updated_at, sent_at, delivered_at, read_at, played_at, failed_at, edited_at, revoked_at
+	FROM wa_outbound_messages`
+
+// GetOutbound returns the message with its participant receipts, sender is optional since the message ids are random.
+// ErrNotFound is returned when the message is not on the log.
+func (r *MessageRepository) GetOutbound(sender, messageID string) (OutboundMessage, error) {
+	messages, err := r.GetOutboundMany(sender, []string{messageID})
+	if err != nil {
+		return OutboundMessage{}, err
+	}
+	if len(messages) == 0 {
+		return OutboundMessage{}, ErrNotFound
+	}
+	return messages[0], nil
+}
+
+// GetOutboundMany returns the messages that are found on the log, in the order of the given ids.
+func (r *MessageRepository) GetOutboundMany(sender string, messageIDs []string) ([]OutboundMessage, error) {
+	result := make([]OutboundMessage, 0, len(messageIDs))
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, 0, len(messageIDs)+1)
+	placeholders := make([]string, len(messageIDs))
+	for i, id := range messageIDs {
+		args = append(args, id)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	query := selectOutbound + " WHERE message_id IN (" + strings.Join(placeholders, ", ") + ")"
+	if sender != "" {
+		args = append(args, sender)
+		query += fmt.Sprintf(" AND sender = $%d", len(args))
+	}
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]OutboundMessage, len(messageIDs))
+	for rows.Next() {
+		msg, err := scanOutbound(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[msg.MessageID] = msg
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range messageIDs {
+		msg, ok := byID[id]
+		if !ok {
+			continue
+		}
+		msg.Receipts, err = r.getReceipts(msg.Sender, msg.MessageID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+func (r *MessageRepository) getReceipts(sender, messageID string) ([]Receipt, error) {
+	rows, err := r.db.Query(`SELECT participant, delivered_at, read_at, played_at FROM wa_outbound_receipts
+		WHERE sender = $1 AND message_id = $2 ORDER BY participant`, sender, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []Receipt
+	for rows.Next() {
+		var receipt Receipt
+		var deliveredAt, readAt, playedAt sql.NullInt64
+		if err = rows.Scan(&receipt.Participant, &deliveredAt, &readAt, &playedAt); err != nil {
+			return nil, err
+		}
+		receipt.DeliveredAt = fromNullMillis(deliveredAt)
+		receipt.ReadAt = fromNullMillis(readAt)
+		receipt.PlayedAt = fromNullMillis(playedAt)
+		receipts = append(receipts, receipt)
+	}
+	return receipts, rows.Err()
+}
+
+func scanOutbound(row interface {
+	Scan(dest ...interface{}) error
+}) (msg OutboundMessage, err error) {
+	var createdAt, updatedAt int64
+	var sentAt, deliveredAt, readAt, playedAt, failedAt, editedAt, revokedAt sql.NullInt64
+	err = row.Scan(&msg.Sender, &msg.MessageID, &msg.Recipient, &msg.Type, &msg.Body, &msg.FileName, &msg.Status, &msg.Error,
+		&createdAt, &updatedAt, &sentAt, &deliveredAt, &readAt, &playedAt, &failedAt, &editedAt, &revokedAt)
+	if err != nil {
+		return msg, err
+	}
+	msg.CreatedAt = fromMillis(createdAt)
+	msg.UpdatedAt = fromMillis(updatedAt)
+	msg.SentAt = fromNullMillis(sentAt)
+	msg.DeliveredAt = fromNullMillis(deliveredAt)
+	msg.ReadAt = fromNullMillis(readAt)
+	msg.PlayedAt = fromNullMillis(playedAt)
+	msg.FailedAt = fromNullMillis(failedAt)
+	msg.EditedAt = fromNullMillis(editedAt)
+	msg.RevokedAt = fromNullMillis(revokedAt)
+	return msg, nil
+}
@@ -0,0 +1,83 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestUpdateOutboundStatus(t *testing.T) {
+	messages := repository.NewMessageRepository(newTestDB(t))
+
+	start := time.Now().Truncate(time.Millisecond)
+	tests := []struct {
+		name       string
+		updates    []string
+		wantStatus string
+		wantError  string
+		wantFailed bool
+	}{
+		{name: "sent", updates: []string{repository.StatusSent}, wantStatus: repository.StatusSent},
+		{name: "in order", updates: []string{repository.StatusSent, repository.StatusDelivered, repository.StatusRead}, wantStatus: repository.StatusRead},
+		{name: "late delivered receipt", updates: []string{repository.StatusSent, repository.StatusRead, repository.StatusDelivered}, wantStatus: repository.StatusRead},
+		{name: "played", updates: []string{repository.StatusRead, repository.StatusPlayed}, wantStatus: repository.StatusPlayed},
+		{name: "failed", updates: []string{repository.StatusFailed}, wantStatus: repository.StatusFailed, wantError: "timeout", wantFailed: true},
+		{name: "failed after sent", updates: []string{repository.StatusSent, repository.StatusFailed}, wantStatus: repository.StatusFailed, wantError: "timeout", wantFailed: true},
+		{name: "failed after delivered", updates: []string{repository.StatusDelivered, repository.StatusFailed}, wantStatus: repository.StatusDelivered},
+		{name: "delivered after failed", updates: []string{repository.StatusFailed, repository.StatusDelivered}, wantStatus: repository.StatusFailed, wantError: "timeout", wantFailed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := repository.OutboundMessage{Sender: "6281", MessageID: tt.name, Recipient: "6282@s.whatsapp.net", Type: "text", Body: "halo"}
+			if err := messages.CreateOutbound(msg); err != nil {
+				t.Fatalf("CreateOutbound: %v", err)
+			}
+			for i, status := range tt.updates {
+				errMsg := ""
+				if status == repository.StatusFailed {
+					errMsg = "timeout"
+				}
+				ok, err := messages.UpdateOutboundStatus(msg.Sender, msg.MessageID, status, start.Add(time.Duration(i)*time.Second), errMsg)
+				if err != nil || !ok {
+					t.Fatalf("UpdateOutboundStatus %s = %v, %v", status, ok, err)
+				}
+			}
+
+			got, err := messages.GetOutbound(msg.Sender, msg.MessageID)
+			if err != nil {
+				t.Fatalf("GetOutbound: %v", err)
+			}
+			if got.Status != tt.wantStatus || got.Error != tt.wantError || (got.FailedAt != nil) != tt.wantFailed {
+				t.Fatalf("message is %s with error %q and failedAt %v, want %s with error %q", got.Status, got.Error, got.FailedAt, tt.wantStatus, tt.wantError)
+			}
+			// every status keeps the time it was first reported, a late receipt still fills its own
+			for i, status := range tt.updates {
+				var at *time.Time
+				switch status {
+				case repository.StatusSent:
+					at = got.SentAt
+				case repository.StatusDelivered:
+					at = got.DeliveredAt
+				case repository.StatusRead:
+					at = got.ReadAt
+				case repository.StatusPlayed:
+					at = got.PlayedAt
+				default:
+					continue
+				}
+				if at == nil || !at.Equal(start.Add(time.Duration(i)*time.Second)) {
+					t.Fatalf("%s at = %v, want %v", status, at, start.Add(time.Duration(i)*time.Second))
+				}
+			}
+		})
+	}
+
+	ok, err := messages.UpdateOutboundStatus("6281", "unknown", repository.StatusRead, start, "")
+	if err != nil || ok {
+		t.Fatalf("UpdateOutboundStatus of an unknown message = %v, %v, want false", ok, err)
+	}
+	if _, err = messages.UpdateOutboundStatus("6281", "sent", "seen", start, ""); err == nil {
+		t.Fatalf("UpdateOutboundStatus of an unknown status should fail")
+	}
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("data not found")
+)
+
+// the timestamps are stored as unix milliseconds so the same statements work on sqlite and postgres
+
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms)
+}
+
+func fromNullMillis(ms sql.NullInt64) *time.Time {
+	if !ms.Valid {
+		return nil
+	}
+	t := time.UnixMilli(ms.Int64)
+	return &t
+}
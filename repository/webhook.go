@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Webhook is the endpoint that receives the events of a session.
+type Webhook struct {
+	Session string `json:"session"`
+	URL     string `json:"url"`
+	// Events is the event filter, every event is sent when it is empty
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DeadLetter is an event that could not be delivered to the webhook after every attempt.
+type DeadLetter struct {
+	EventID    string    `json:"eventId"`
+	Session    string    `json:"session"`
+	URL        string    `json:"url"`
+	Event      string    `json:"event"`
+	Payload    string    `json:"payload"`
+	Attempts   int       `json:"attempts"`
+	LastStatus int       `json:"lastStatus,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	FailedAt   time.Time `json:"failedAt"`
+}
+
+// WebhookRepository stores the webhooks of the sessions and the dead letters.
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Get returns the webhook of the session, ErrNotFound is returned when the session has none.
+func (r *WebhookRepository) Get(session string) (Webhook, error) {
+	var webhook Webhook
+	var events string
+	var createdAt, updatedAt int64
+	err := r.db.QueryRow(`SELECT session, url, events, enabled, created_at, updated_at FROM wa_webhooks WHERE session = $1`, session).
+		Scan(&webhook.Session, &webhook.URL, &events, &webhook.Enabled, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhook, ErrNotFound
+	}
+	if err != nil {
+		return webhook, err
+	}
+	webhook.Events = splitList(events)
+	webhook.CreatedAt = fromMillis(createdAt)
+	webhook.UpdatedAt = fromMillis(updatedAt)
+	return webhook, nil
+}
+
+// Put creates or replaces the webhook of the session.
+func (r *WebhookRepository) Put(webhook Webhook) error {
+	now := toMillis(time.Now())
+	_, err := r.db.Exec(`INSERT INTO wa_webhooks (session, url, events, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (session) DO UPDATE SET
+		url = excluded.url, events = excluded.events, enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		webhook.Session, webhook.URL, strings.Join(webhook.Events, ","), webhook.Enabled, now)
+	return err
+}
+
+// Delete removes the webhook of the session, it reports false when the session has none.
+func (r *WebhookRepository) Delete(session string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM wa_webhooks WHERE session = $1`, session)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CreateDeadLetter stores an undelivered event, an event that fails again replaces its previous dead letter.
+func (r *WebhookRepository) CreateDeadLetter(letter DeadLetter) error {
+	_, err := r.db.Exec(`INSERT INTO wa_webhook_dead_letters
+		(event_id, session, url, event, payload, attempts, last_status, last_error, created_at, failed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (event_id) DO UPDATE SET
+		url = excluded.url, attempts = wa_webhook_dead_letters.attempts + excluded.attempts,
+		last_status = excluded.last_status, last_error = excluded.last_error, failed_at = excluded.failed_at`,
+		letter.EventID, letter.Session, letter.URL, letter.Event, letter.Payload, letter.Attempts, letter.LastStatus, letter.LastError,
+		toMillis(letter.CreatedAt), toMillis(letter.FailedAt))
+	return err
+}
+
+// ListDeadLetters returns the dead letters newest first, session is optional.
+func (r *WebhookRepository) ListDeadLetters(session string, limit, offset int) ([]DeadLetter, error) {
+	query := `SELECT event_id, session, url, event, payload, attempts, last_status, last_error, created_at, failed_at
+		FROM wa_webhook_dead_letters`
+	// sqlite numbers the $n placeholders in the order they appear, the args follow the same order
+	args := []interface{}{}
+	if session != "" {
+		args = append(args, session)
+		query += ` WHERE session = $1`
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(` ORDER BY failed_at DESC, event_id LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []DeadLetter{}
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, rows.Err()
+}
+
+// GetDeadLetter returns the dead letter of the event, ErrNotFound is returned when there is none.
+func (r *WebhookRepository) GetDeadLetter(eventID string) (DeadLetter, error) {
+	letter, err := scanDeadLetter(r.db.QueryRow(`SELECT event_id, session, url, event, payload, attempts, last_status, last_error,
+		created_at, failed_at FROM wa_webhook_dead_letters WHERE event_id = $1`, eventID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return letter, ErrNotFound
+	}
+	return letter, err
+}
+
+// DeleteDeadLetter removes the dead letter of the event, e.g. once it is delivered by a retry.
+func (r *WebhookRepository) DeleteDeadLetter(eventID string) error {
+	_, err := r.db.Exec(`DELETE FROM wa_webhook_dead_letters WHERE event_id = $1`, eventID)
+	return err
+}
+
+func scanDeadLetter(row interface {
+	Scan(dest ...interface{}) error
+}) (letter DeadLetter, err error) {
+	var createdAt, failedAt int64
+	err = row.Scan(&letter.EventID, &letter.Session, &letter.URL, &letter.Event, &letter.Payload, &letter.Attempts,
+		&letter.LastStatus, &letter.LastError, &createdAt, &failedAt)
+	if err != nil {
+		return letter, err
+	}
+	letter.CreatedAt = fromMillis(createdAt)
+	letter.FailedAt = fromMillis(failedAt)
+	return letter, nil
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,56 @@
+package repository_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/repository"
+)
+
+func TestListDeadLetters(t *testing.T) {
+	webhooks := repository.NewWebhookRepository(newTestDB(t))
+
+	now := time.Now()
+	letters := []repository.DeadLetter{
+		{EventID: "e1", Session: "6281", Event: "message", FailedAt: now.Add(-3 * time.Minute)},
+		{EventID: "e2", Session: "6282", Event: "message", FailedAt: now.Add(-2 * time.Minute)},
+		{EventID: "e3", Session: "6281", Event: "receipt", FailedAt: now.Add(-time.Minute)},
+	}
+	for _, letter := range letters {
+		letter.CreatedAt, letter.Attempts = letter.FailedAt, 1
+		if err := webhooks.CreateDeadLetter(letter); err != nil {
+			t.Fatalf("CreateDeadLetter %s: %v", letter.EventID, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		session string
+		limit   int
+		offset  int
+		want    []string
+	}{
+		{name: "all", limit: 10, want: []string{"e3", "e2", "e1"}},
+		{name: "all paged", limit: 1, offset: 1, want: []string{"e2"}},
+		{name: "session", session: "6281", limit: 10, want: []string{"e3", "e1"}},
+		{name: "session paged", session: "6281", limit: 1, offset: 1, want: []string{"e1"}},
+		{name: "other session", session: "6282", limit: 10, want: []string{"e2"}},
+		{name: "unknown session", session: "6289", limit: 10, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := webhooks.ListDeadLetters(tt.session, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("ListDeadLetters: %v", err)
+			}
+			ids := []string{}
+			for _, letter := range got {
+				ids = append(ids, letter.EventID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Fatalf("got %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
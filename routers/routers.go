@@ -2,6 +2,7 @@ package routers
 
 import (
 	"whatsapp_multi_session_general/handler"
+	"whatsapp_multi_session_general/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,6 +20,8 @@ func NewRoutes(handler handler.Handler) Router {
 func (r Router) V1(router *gin.Engine) *gin.Engine {
 	// Define routers
 	router.GET("/qr", r.Handler.HandleQR)
+	router.GET("/qr/stream", r.Handler.HandleQRStream)
+	router.GET("/pair-code", r.Handler.HandlePairCode)
 	router.POST("/presence", r.Handler.ServeSendPresence)
 	router.POST("/send", r.Handler.ServeSendText)
 	router.POST("/send-bulk", r.Handler.ServeSendTextBulk)
@@ -26,9 +29,43 @@ func (r Router) V1(router *gin.Engine) *gin.Engine {
 	router.POST("/check-user", r.Handler.ServeCheckUser)
 	router.POST("/check-user-single", r.Handler.ServeCheckUserSingle)
 	router.POST("/upload", r.Handler.NewUploadHandler)
+	router.POST("/send-location", r.Handler.ServeSendLocation)
+	router.POST("/send-contact", r.Handler.ServeSendContact)
+	router.POST("/send-reaction", r.Handler.ServeSendReaction)
 	router.GET("/devices", r.Handler.ServeAllDevices)
 	router.GET("/devices/:jid", r.Handler.ServeDetailDevices)
+	router.DELETE("/devices/:jid", r.Handler.DeleteDevice)
 	router.POST("/logout", r.Handler.Logout)
+	router.GET("/messages", r.Handler.ServeMessageStatuses)
+	router.GET("/messages/:id", r.Handler.ServeMessageStatus)
+	router.PUT("/messages/:id", r.Handler.ServeEditMessage)
+	router.DELETE("/messages/:id", r.Handler.RevokeMessage)
+	router.GET("/chats", r.Handler.ServeChats)
+	router.GET("/chats/:chat/messages", r.Handler.ServeChatMessages)
+	router.GET("/auto-replies", r.Handler.ServeAutoReplies)
+	router.POST("/auto-replies", r.Handler.CreateAutoReply)
+	router.GET("/auto-replies/:id", r.Handler.ServeAutoReply)
+	router.PUT("/auto-replies/:id", r.Handler.UpdateAutoReply)
+	router.DELETE("/auto-replies/:id", r.Handler.DeleteAutoReply)
+	router.GET("/business-hours", r.Handler.ServeBusinessHours)
+	router.PUT("/business-hours", r.Handler.PutBusinessHours)
+	router.DELETE("/business-hours", r.Handler.DeleteBusinessHours)
+	router.GET("/call-settings", r.Handler.ServeCallSettings)
+	router.PUT("/call-settings", r.Handler.PutCallSettings)
+	router.DELETE("/call-settings", r.Handler.DeleteCallSettings)
+	router.GET("/calls", r.Handler.ServeCalls)
+
+	router.GET("/events/stream", middleware.StreamAuth(), r.Handler.ServeEventStream)
+	router.GET("/media/:id", middleware.MediaAuth(), r.Handler.ServeMedia)
+
+	admin := router.Group("/admin", middleware.AdminAuth())
+	admin.POST("/backup", r.Handler.ServeBackup)
+	admin.POST("/restore", r.Handler.ServeRestore)
+	admin.GET("/webhooks/:sender", r.Handler.ServeWebhook)
+	admin.PUT("/webhooks/:sender", r.Handler.PutWebhook)
+	admin.DELETE("/webhooks/:sender", r.Handler.DeleteWebhook)
+	admin.GET("/dead-letters", r.Handler.ServeDeadLetters)
+	admin.POST("/dead-letters/:id/redeliver", r.Handler.RedeliverDeadLetter)
 
 	return router
 }
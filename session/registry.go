@@ -0,0 +1,166 @@
+package session
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"go.mau.fi/whatsmeow"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found, tolong hit endpoint untuk melakukan qrcode")
+)
+
+// Hook is called by the Registry when a client is registered or removed.
+// hooks are called outside the registry lock, so it is safe to call the registry from inside a hook.
+type Hook func(user string, client *whatsmeow.Client)
+
+// Registry keeps the whatsmeow client and the lifecycle state of every session keyed by the sender number (jid.User).
+// it is safe to use from multiple goroutines.
+type Registry struct {
+	mu      sync.RWMutex
+	clients map[string]*whatsmeow.Client
+	states  map[string]*Status
+
+	hookMu   sync.RWMutex
+	onPut    []Hook
+	onDelete []Hook
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		clients: make(map[string]*whatsmeow.Client),
+		states:  make(map[string]*Status),
+	}
+}
+
+// Get returns the client registered for the user, ok is false when there is no session.
+func (r *Registry) Get(user string) (client *whatsmeow.Client, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	client, ok = r.clients[user]
+	return client, ok && client != nil
+}
+
+// Put registers the client for the user, an existing different client for the same user is replaced
+// and the delete hooks are called for it.
+func (r *Registry) Put(user string, client *whatsmeow.Client) {
+	if client == nil {
+		return
+	}
+
+	r.mu.Lock()
+	previous, exists := r.clients[user]
+	r.clients[user] = client
+	r.mu.Unlock()
+
+	if exists && previous != client {
+		r.fire(r.deleteHooks(), user, previous)
+	}
+	if !exists || previous != client {
+		r.fire(r.putHooks(), user, client)
+	}
+}
+
+// Delete removes the session of the user and returns the removed client.
+func (r *Registry) Delete(user string) (client *whatsmeow.Client, ok bool) {
+	r.mu.Lock()
+	client, ok = r.clients[user]
+	delete(r.clients, user)
+	r.mu.Unlock()
+
+	if ok {
+		r.fire(r.deleteHooks(), user, client)
+	}
+	return client, ok
+}
+
+// CompareAndDelete removes the session of the user only if it is still owned by the given client.
+func (r *Registry) CompareAndDelete(user string, client *whatsmeow.Client) bool {
+	r.mu.Lock()
+	current, ok := r.clients[user]
+	if !ok || current != client {
+		r.mu.Unlock()
+		return false
+	}
+	delete(r.clients, user)
+	r.mu.Unlock()
+
+	r.fire(r.deleteHooks(), user, client)
+	return true
+}
+
+// UserOf returns the user the client is registered for.
+func (r *Registry) UserOf(client *whatsmeow.Client) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for user, current := range r.clients {
+		if current == client {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+// List returns a snapshot of every registered session, the returned map can be iterated without locking.
+func (r *Registry) List() map[string]*whatsmeow.Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	snapshot := make(map[string]*whatsmeow.Client, len(r.clients))
+	for user, client := range r.clients {
+		snapshot[user] = client
+	}
+	return snapshot
+}
+
+// Users returns the sorted list of registered users.
+func (r *Registry) Users() []string {
+	r.mu.RLock()
+	users := make([]string, 0, len(r.clients))
+	for user := range r.clients {
+		users = append(users, user)
+	}
+	r.mu.RUnlock()
+	sort.Strings(users)
+	return users
+}
+
+// Len returns the number of registered sessions.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.clients)
+}
+
+// OnPut adds a hook that is called every time a new client is registered.
+func (r *Registry) OnPut(hook Hook) {
+	r.hookMu.Lock()
+	r.onPut = append(r.onPut, hook)
+	r.hookMu.Unlock()
+}
+
+// OnDelete adds a hook that is called every time a client is removed or replaced.
+func (r *Registry) OnDelete(hook Hook) {
+	r.hookMu.Lock()
+	r.onDelete = append(r.onDelete, hook)
+	r.hookMu.Unlock()
+}
+
+func (r *Registry) putHooks() []Hook {
+	r.hookMu.RLock()
+	defer r.hookMu.RUnlock()
+	return append([]Hook(nil), r.onPut...)
+}
+
+func (r *Registry) deleteHooks() []Hook {
+	r.hookMu.RLock()
+	defer r.hookMu.RUnlock()
+	return append([]Hook(nil), r.onDelete...)
+}
+
+func (r *Registry) fire(hooks []Hook, user string, client *whatsmeow.Client) {
+	for _, hook := range hooks {
+		hook(user, client)
+	}
+}
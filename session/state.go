@@ -0,0 +1,88 @@
+package session
+
+import (
+	"time"
+)
+
+// State is the lifecycle state of a session.
+type State string
+
+const (
+	StatePairing      State = "pairing"
+	StateConnecting   State = "connecting"
+	StateConnected    State = "connected"
+	StateDisconnected State = "disconnected"
+	StateLoggedOut    State = "logged-out"
+	StateBanned       State = "banned"
+)
+
+// maxHistory is the number of transitions kept for every session
+const maxHistory = 20
+
+// Transition is a single change of the session state.
+type Transition struct {
+	From  State     `json:"from"`
+	To    State     `json:"to"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Status is the tracked state of a session with the last error and the latest transitions.
+type Status struct {
+	State     State        `json:"state"`
+	LastError string       `json:"lastError,omitempty"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+	History   []Transition `json:"history"`
+}
+
+// SetState moves the session of the user to the given state.
+// the error (if any) is kept as the last error until the session is connected again.
+func (r *Registry) SetState(user string, state State, err error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, ok := r.states[user]
+	if !ok {
+		status = &Status{}
+		r.states[user] = status
+	}
+
+	transition := Transition{From: status.State, To: state, At: now}
+	if err != nil {
+		transition.Error = err.Error()
+		status.LastError = err.Error()
+	} else if state == StateConnected {
+		status.LastError = ""
+	}
+
+	status.State = state
+	status.UpdatedAt = now
+	status.History = append(status.History, transition)
+	if len(status.History) > maxHistory {
+		status.History = status.History[len(status.History)-maxHistory:]
+	}
+}
+
+// Status returns a copy of the tracked status of the user.
+func (r *Registry) Status(user string) (Status, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status, ok := r.states[user]
+	if !ok {
+		return Status{}, false
+	}
+
+	snapshot := *status
+	snapshot.History = append([]Transition(nil), status.History...)
+	return snapshot, true
+}
+
+// ForgetState drops the tracked status of the user.
+func (r *Registry) ForgetState(user string) {
+	r.mu.Lock()
+	delete(r.states, user)
+	r.mu.Unlock()
+}
@@ -0,0 +1,149 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// sendBuffer is the number of events queued for a client before it is considered too slow
+	sendBuffer = 256
+
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+
+	maxMessageSize = 4096
+)
+
+// command is a message sent by the client to change its subscription, e.g.
+//
+//	{"action": "subscribe", "senders": ["62811"], "events": ["message", "receipt"]}
+type command struct {
+	Action string `json:"action"`
+	Filter
+}
+
+// Client is a single websocket connection and its subscription.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+
+	mu     sync.RWMutex
+	filter Filter
+
+	queue     chan []byte
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Serve registers the connection on the hub and blocks until it is closed.
+func (h *Hub) Serve(conn *websocket.Conn, filter Filter) {
+	client := &Client{
+		hub:    h,
+		conn:   conn,
+		filter: filter,
+		queue:  make(chan []byte, sendBuffer),
+		done:   make(chan struct{}),
+	}
+	if !h.add(client) {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server is shutting down"), time.Now().Add(writeWait))
+		_ = conn.Close()
+		return
+	}
+	defer h.remove(client)
+
+	go client.writePump()
+	client.readPump()
+}
+
+// Filter returns the current subscription of the client.
+func (c *Client) Filter() Filter {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.filter
+}
+
+// Close disconnects the client.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *Client) send(payload []byte) {
+	select {
+	case c.queue <- payload:
+	default:
+		fmt.Println("stream client is too slow, the connection is closed")
+		c.Close()
+	}
+}
+
+// readPump reads the subscription commands of the client until the connection is closed.
+func (c *Client) readPump() {
+	defer c.Close()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var cmd command
+		if err = json.Unmarshal(data, &cmd); err != nil || cmd.Action != "subscribe" {
+			c.reply(map[string]string{"message": "unknown command, use {\"action\":\"subscribe\",\"senders\":[],\"events\":[]}"})
+			continue
+		}
+		c.mu.Lock()
+		c.filter = cmd.Filter
+		c.mu.Unlock()
+		c.reply(map[string]interface{}{"message": "subscribed", "filter": cmd.Filter})
+	}
+}
+
+func (c *Client) reply(message interface{}) {
+	payload, err := json.Marshal(message)
+	if err == nil {
+		c.send(payload)
+	}
+}
+
+// writePump writes the queued events and the pings, it is the only writer of the connection.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload := <-c.queue:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"whatsapp_multi_session_general/primitive"
+)
+
+// Filter selects the events a connection receives, an empty list matches everything.
+type Filter struct {
+	Senders []string `json:"senders"`
+	Events  []string `json:"events"`
+}
+
+// Matches reports whether the event passes the filter.
+func (f Filter) Matches(evt primitive.Event) bool {
+	return contains(f.Senders, evt.Session) && contains(f.Events, evt.Event)
+}
+
+func contains(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Hub fans the published events out to the connected websocket clients.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// Publish sends the event to every client whose filter matches, a client that can not keep up is disconnected
+// instead of blocking the whatsmeow event handlers.
+func (h *Hub) Publish(evt primitive.Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.clients) == 0 {
+		return
+	}
+
+	// serialized once, the payload is the same as the webhook body
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		fmt.Printf("err json.Marshal stream %s : %v \n", evt.ID, err)
+		return
+	}
+	for client := range h.clients {
+		if client.Filter().Matches(evt) {
+			client.send(payload)
+		}
+	}
+}
+
+// Len returns the number of connected clients.
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Close disconnects every client, new connections are refused afterwards.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*Client, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.Unlock()
+
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+func (h *Hub) add(client *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.clients[client] = struct{}{}
+	return true
+}
+
+func (h *Hub) remove(client *Client) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+}
@@ -0,0 +1,334 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+	"whatsapp_multi_session_general/primitive"
+	"whatsapp_multi_session_general/repository"
+)
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultMaxAttempts    = 6
+	defaultInitialBackoff = 5 * time.Second
+	defaultMaxBackoff     = 10 * time.Minute
+	defaultWorkers        = 4
+	defaultQueueSize      = 1000
+
+	userAgent = "whatsapp_multi_session_general-webhook"
+)
+
+var (
+	ErrQueueFull = errors.New("webhook queue is full")
+	ErrStopped   = errors.New("webhook dispatcher is stopped")
+)
+
+// delivery is a single event on its way to a webhook.
+type delivery struct {
+	eventID   string
+	session   string
+	event     string
+	url       string
+	payload   []byte
+	createdAt time.Time
+	attempts  int
+}
+
+// Dispatcher delivers the events of the sessions to their webhooks, a failed delivery is retried with
+// exponential backoff and moved to the dead letters once every attempt failed.
+type Dispatcher struct {
+	Webhooks *repository.WebhookRepository
+
+	conf   config.Webhook
+	secret string
+	client *http.Client
+	queue  chan *delivery
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewDispatcher(webhooks *repository.WebhookRepository) *Dispatcher {
+	conf := config.Conf.Webhook
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+	if conf.MaxAttempts <= 0 {
+		conf.MaxAttempts = defaultMaxAttempts
+	}
+	if conf.InitialBackoff <= 0 {
+		conf.InitialBackoff = defaultInitialBackoff
+	}
+	if conf.MaxBackoff <= 0 {
+		conf.MaxBackoff = defaultMaxBackoff
+	}
+	if conf.Workers <= 0 {
+		conf.Workers = defaultWorkers
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = defaultQueueSize
+	}
+
+	return &Dispatcher{
+		Webhooks: webhooks,
+		conf:     conf,
+		secret:   config.Conf.SignString,
+		client:   &http.Client{Timeout: conf.Timeout},
+		queue:    make(chan *delivery, conf.QueueSize),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Start runs the delivery workers.
+func (d *Dispatcher) Start() {
+	for i := 0; i < d.conf.Workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+}
+
+// Stop stops the workers, the events that are still queued are moved to the dead letters
+// so they can be redelivered after the restart.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopped)
+	})
+	d.wg.Wait()
+
+	for {
+		select {
+		case item := <-d.queue:
+			d.deadLetter(item, 0, ErrStopped)
+		default:
+			return
+		}
+	}
+}
+
+// Handle queues the event for the webhook of its session, it does not block the caller.
+// the webhook of the session is looked up by the worker, the url of the delivery is empty until then.
+func (d *Dispatcher) Handle(evt primitive.Event) {
+	if !d.conf.Enable {
+		return
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		fmt.Printf("err json.Marshal webhook %s : %v \n", evt.ID, err)
+		return
+	}
+
+	d.enqueue(&delivery{
+		eventID:   evt.ID,
+		session:   evt.Session,
+		event:     evt.Event,
+		payload:   payload,
+		createdAt: evt.Timestamp,
+	})
+}
+
+// resolve sets the url of a delivery that was not resolved yet, it reports false when the event
+// of the session is not sent to any webhook.
+func (d *Dispatcher) resolve(item *delivery) bool {
+	if item.url != "" {
+		return true
+	}
+	url, ok := d.target(item.session, item.event)
+	item.url = url
+	return ok
+}
+
+// target returns the url the event of the session is sent to, the webhook of the session is used
+// when it is set, otherwise the webhook url of the config.
+func (d *Dispatcher) target(session, event string) (string, bool) {
+	url, events := d.conf.URL, d.conf.Events
+
+	webhook, err := d.Webhooks.Get(session)
+	switch {
+	case err == nil:
+		if !webhook.Enabled {
+			return "", false
+		}
+		url, events = webhook.URL, webhook.Events
+	case !errors.Is(err, repository.ErrNotFound):
+		fmt.Printf("err Webhooks.Get %s : %v \n", session, err)
+	}
+
+	if url == "" {
+		return "", false
+	}
+	if len(events) == 0 {
+		return url, true
+	}
+	for _, name := range events {
+		if name == event {
+			return url, true
+		}
+	}
+	return "", false
+}
+
+func (d *Dispatcher) enqueue(item *delivery) {
+	select {
+	case <-d.stopped:
+		d.deadLetter(item, 0, ErrStopped)
+		return
+	default:
+	}
+
+	select {
+	case d.queue <- item:
+	default:
+		d.deadLetter(item, 0, ErrQueueFull)
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stopped:
+			return
+		case item := <-d.queue:
+			d.process(item)
+		}
+	}
+}
+
+// process delivers the event once and schedules the next attempt when it fails.
+func (d *Dispatcher) process(item *delivery) {
+	if !d.resolve(item) {
+		return
+	}
+	item.attempts++
+	status, err := d.send(item)
+	if err == nil {
+		return
+	}
+
+	if !retryable(status) || item.attempts >= d.conf.MaxAttempts {
+		d.deadLetter(item, status, err)
+		return
+	}
+
+	delay := d.backoff(item.attempts - 1)
+	fmt.Printf("webhook %s of %s attempt %d failed, retry in %s: %v \n", item.eventID, item.session, item.attempts, delay, err)
+	time.AfterFunc(delay, func() {
+		d.enqueue(item)
+	})
+}
+
+// send posts the signed event to the webhook, any status other than 2xx is an error.
+func (d *Dispatcher) send(item *delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, item.url, bytes.NewReader(item.payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEventID, item.eventID)
+	req.Header.Set(HeaderEvent, item.event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.secret, timestamp, item.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) deadLetter(item *delivery, status int, reason error) {
+	// an event that is dropped before the worker picked it up is only kept when it has a webhook
+	if !d.resolve(item) {
+		return
+	}
+	fmt.Printf("webhook %s of %s is moved to the dead letters after %d attempts: %v \n", item.eventID, item.session, item.attempts, reason)
+	err := d.Webhooks.CreateDeadLetter(repository.DeadLetter{
+		EventID:    item.eventID,
+		Session:    item.session,
+		URL:        item.url,
+		Event:      item.event,
+		Payload:    string(item.payload),
+		Attempts:   item.attempts,
+		LastStatus: status,
+		LastError:  reason.Error(),
+		CreatedAt:  item.createdAt,
+		FailedAt:   time.Now(),
+	})
+	if err != nil {
+		fmt.Printf("err Webhooks.CreateDeadLetter %s : %v \n", item.eventID, err)
+	}
+}
+
+// Redeliver sends a dead letter again right away, to the current webhook of the session when it still has one.
+// the dead letter is removed when it is delivered, otherwise its attempts and last error are updated.
+func (d *Dispatcher) Redeliver(eventID string) (status int, err error) {
+	letter, err := d.Webhooks.GetDeadLetter(eventID)
+	if err != nil {
+		return 0, err
+	}
+
+	item := &delivery{
+		eventID:   letter.EventID,
+		session:   letter.Session,
+		event:     letter.Event,
+		url:       letter.URL,
+		payload:   []byte(letter.Payload),
+		createdAt: letter.CreatedAt,
+		attempts:  1,
+	}
+	if url, ok := d.target(letter.Session, letter.Event); ok {
+		item.url = url
+	}
+
+	status, err = d.send(item)
+	if err != nil {
+		d.deadLetter(item, status, err)
+		return status, err
+	}
+	return status, d.Webhooks.DeleteDeadLetter(eventID)
+}
+
+// retryable reports whether a failed delivery can succeed later, a client error other than
+// timeout and rate limit means the webhook rejects the event.
+func retryable(status int) bool {
+	if status == 0 || status >= 500 {
+		return true
+	}
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// backoff returns the delay before the next attempt, it doubles on every attempt up to the max backoff
+// with a random jitter of up to half of the delay.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.conf.InitialBackoff
+	for i := 0; i < attempt && delay < d.conf.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.conf.MaxBackoff {
+		delay = d.conf.MaxBackoff
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"whatsapp_multi_session_general/config"
+)
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{conf: config.Webhook{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}}
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{attempt: 0, delay: time.Second},
+		{attempt: 1, delay: 2 * time.Second},
+		{attempt: 3, delay: 8 * time.Second},
+		{attempt: 4, delay: 10 * time.Second},
+		{attempt: 100, delay: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		// the jitter is random, the delay is between half of the delay and the delay
+		for i := 0; i < 50; i++ {
+			if got := d.backoff(tt.attempt); got < tt.delay/2 || got > tt.delay {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.attempt, got, tt.delay/2, tt.delay)
+			}
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{status: 0, want: true},
+		{status: http.StatusInternalServerError, want: true},
+		{status: http.StatusBadGateway, want: true},
+		{status: http.StatusRequestTimeout, want: true},
+		{status: http.StatusTooManyRequests, want: true},
+		{status: http.StatusBadRequest, want: false},
+		{status: http.StatusUnauthorized, want: false},
+		{status: http.StatusNotFound, want: false},
+		{status: http.StatusGone, want: false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.status); got != tt.want {
+			t.Fatalf("retryable(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
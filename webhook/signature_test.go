@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"1","event":"message"}`)
+	now := time.Now().Unix()
+	signature := Sign("secret", now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		tolerance time.Duration
+		want      bool
+	}{
+		{name: "valid", secret: "secret", timestamp: now, body: body, signature: signature, tolerance: time.Minute, want: true},
+		{name: "no tolerance", secret: "secret", timestamp: now, body: body, signature: signature, want: true},
+		{name: "wrong secret", secret: "other", timestamp: now, body: body, signature: signature, tolerance: time.Minute},
+		{name: "changed body", secret: "secret", timestamp: now, body: []byte(`{}`), signature: signature, tolerance: time.Minute},
+		{name: "changed timestamp", secret: "secret", timestamp: now + 1, body: body, signature: signature, tolerance: time.Minute},
+		{name: "no prefix", secret: "secret", timestamp: now, body: body, signature: signature[len(signaturePrefix):], tolerance: time.Minute},
+		{
+			name: "expired", secret: "secret", timestamp: now - 600, body: body,
+			signature: Sign("secret", now-600, body), tolerance: time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.body, tt.signature, tt.tolerance); got != tt.want {
+				t.Fatalf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}